@@ -3,30 +3,44 @@ package database
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	sqlfile "github.com/donnyhardyanto/dxlib/database/protected/sqlfile"
 	mssql "github.com/microsoft/go-mssqldb"
 	goOra "github.com/sijms/go-ora/v2"
 	"net"
+	"net/url"
+	"runtime"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/jmoiron/sqlx"
 	pq "github.com/knetic/go-namedparameterquery"
-	_ "github.com/lib/pq"
+	libPq "github.com/lib/pq"
 	_ "github.com/sijms/go-ora/v2"
 
 	"github.com/donnyhardyanto/dxlib/configuration"
 	"github.com/donnyhardyanto/dxlib/database/database_type"
 	"github.com/donnyhardyanto/dxlib/database/protected/db"
 	"github.com/donnyhardyanto/dxlib/database/protected/dbtx"
+	databaseProtectedUtils "github.com/donnyhardyanto/dxlib/database/protected/utils"
 	"github.com/donnyhardyanto/dxlib/log"
 	"github.com/donnyhardyanto/dxlib/utils"
+	utilsJSON "github.com/donnyhardyanto/dxlib/utils/json"
 	utilsSql "github.com/donnyhardyanto/dxlib/utils/security"
 )
 
+var ErrDatabaseNotConnected = errors.New(`DATABASE_NOT_CONNECTED`)
+
+var ErrDatabaseEmptyWhere = errors.New(`DATABASE_EMPTY_WHERE`)
+
+var ErrDatabaseEmptySet = errors.New(`DATABASE_EMPTY_SET`)
+
+var ErrDatabaseAlreadyExists = errors.New(`DATABASE_ALREADY_EXISTS`)
+
 type DXDatabaseEventFunc func(dm *DXDatabase, err error)
 
 type DXDatabaseTxCallback func(dtx *DXDatabaseTx) (err error)
@@ -46,11 +60,22 @@ const (
 
 type DXDatabaseTx struct {
 	*sqlx.Tx
-	Log *log.DXLog
+	Log      *log.DXLog
+	finished atomic.Bool
+}
+
+func (dtx *DXDatabaseTx) commit() (err error) {
+	dtx.finished.Store(true)
+	return dtx.Tx.Commit()
+}
+
+func (dtx *DXDatabaseTx) rollback() (err error) {
+	dtx.finished.Store(true)
+	return dtx.Tx.Rollback()
 }
 
 func (dtx *DXDatabaseTx) Commit() (err error) {
-	err = dtx.Tx.Commit()
+	err = dtx.commit()
 	if err != nil {
 		dtx.Log.Errorf("TX_ERROR_IN_COMMIT: (%v)", err.Error())
 		return err
@@ -59,7 +84,7 @@ func (dtx *DXDatabaseTx) Commit() (err error) {
 }
 
 func (dtx *DXDatabaseTx) Rollback() (err error) {
-	err = dtx.Tx.Rollback()
+	err = dtx.rollback()
 	if err != nil {
 		dtx.Log.Errorf("TX_ERROR_IN_ROLLBACK: (%v)", err.Error())
 		return err
@@ -82,6 +107,7 @@ func (dtx *DXDatabaseTx) Finish(log *log.DXLog, err error) {
 }
 
 type DXDatabase struct {
+	Owner                        *DXDatabaseManager
 	NameId                       string
 	IsConfigured                 bool
 	DatabaseType                 database_type.DXDatabaseType
@@ -98,6 +124,22 @@ type DXDatabase struct {
 	NonSensitiveConnectionString string
 	OnCannotConnect              DXDatabaseEventFunc
 	CreateScriptFiles            []string
+	ReadTimeoutSec               int
+	WriteTimeoutSec              int
+	SlowQueryThresholdMs         int
+	ApplicationName              string
+	QueryHooks                   []DXDatabaseQueryHook
+	ConnectRetries               int
+	ConnectRetryDelay            time.Duration
+	OnConnectStatements          []string
+	OnConnect                    DXDatabaseOnConnectFunc
+	ReadOnly                     bool
+	HealthcheckQuery             string
+	AllowTruncate                bool
+	TraceAcquisition             bool
+	TraceAcquisitionThresholdMs  int
+	typeMappings                 map[string]dxDatabaseTypeMapping
+	columnTypes                  map[string]string
 }
 
 func (d *DXDatabase) TransactionBegin(isolationLevel DXDatabaseTxIsolationLevel) (dtx *DXDatabaseTx, err error) {
@@ -108,38 +150,79 @@ func (d *DXDatabase) TransactionBegin(isolationLevel DXDatabaseTxIsolationLevel)
 	driverName := d.Connection.DriverName()
 	switch driverName {
 	case "oracle":
-		tx, err := d.Connection.BeginTxx(context.Background(), &sql.TxOptions{
+		return d.begin(context.Background(), &sql.TxOptions{
 			ReadOnly: false,
-		})
-		if err != nil {
-			return nil, err
-		}
-		dtx = &DXDatabaseTx{
-			Tx:  tx,
-			Log: &log.Log,
-		}
-		return dtx, nil
+		}, &log.Log)
 	}
 
-	tx, err := d.Connection.BeginTxx(context.Background(), &sql.TxOptions{
+	return d.begin(context.Background(), &sql.TxOptions{
 		Isolation: isolationLevel,
 		ReadOnly:  false,
-	})
+	}, &log.Log)
+}
+
+func (d *DXDatabase) setConnected(connected bool) {
+	if d.Connected == connected {
+		return
+	}
+	d.Connected = connected
+	if d.Owner != nil {
+		d.Owner.notifyStateChange(d.NameId, connected)
+	}
+}
+
+func (d *DXDatabase) Ping(ctx context.Context) (err error) {
+	if (!d.Connected) || (d.Connection == nil) {
+		return fmt.Errorf(`%w:%s`, ErrDatabaseNotConnected, d.NameId)
+	}
+	err = d.Connection.PingContext(ctx)
 	if err != nil {
-		return nil, err
+		log.Log.Warnf("Database %v ping failed: %v", d.NameId, err.Error())
+		return err
 	}
-	dtx = &DXDatabaseTx{
-		Tx:  tx,
-		Log: &log.Log,
+	return nil
+}
+
+// HealthQuery runs healthcheck_query and fails when it errors or returns no row, a truer readiness signal than Ping.
+// The default query only proves the database answers queries, a replica can be configured with a query that returns no
+// row when its replication lag is too high.
+func (d *DXDatabase) HealthQuery(ctx context.Context) (err error) {
+	if (!d.Connected) || (d.Connection == nil) {
+		return fmt.Errorf(`%w:%s`, ErrDatabaseNotConnected, d.NameId)
 	}
-	return dtx, nil
+	query := d.HealthcheckQuery
+	if query == "" {
+		query = `SELECT 1`
+		if d.Connection.DriverName() == "oracle" {
+			query = `SELECT 1 FROM DUAL`
+		}
+	}
+	ctx, cancel := d.StatementContext(WithStatementKind(ctx, StatementKindRead), query)
+	defer cancel()
+	rows, err := d.Connection.QueryContext(ctx, query)
+	if err != nil {
+		log.Log.Warnf("Database %v health query failed: %v", d.NameId, err.Error())
+		return err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	if !rows.Next() {
+		err = rows.Err()
+		if err == nil {
+			err = fmt.Errorf("database %s health query returned no row", d.NameId)
+		}
+		log.Log.Warnf("Database %v health query failed: %v", d.NameId, err.Error())
+		return err
+	}
+	return nil
 }
 
 func (d *DXDatabase) CheckConnection() (err error) {
 	dbConn, err := d.Connection.Conn(context.Background())
 	if err != nil {
 		log.Log.Warnf("Database %v CheckConnection() failed: %v", d.NameId, err.Error())
-		d.Connected = false
+		d.setConnected(false)
 		return err
 	}
 	defer func() {
@@ -150,12 +233,12 @@ func (d *DXDatabase) CheckConnection() (err error) {
 	defer cancel()
 
 	if err := dbConn.PingContext(ctx); err != nil {
-		d.Connected = false
+		d.setConnected(false)
 		log.Log.Warnf("Database %v ping failed: %v", d.NameId, err.Error())
 		return err
 	}
 	log.Log.Tracef("Database %v ping success with result CheckConnection: %v", d.NameId, d.Connected)
-	d.Connected = true
+	d.setConnected(true)
 	return err
 }
 
@@ -184,6 +267,10 @@ func (d *DXDatabase) CheckConnectionAndReconnect() (err error) {
 }
 
 func (d *DXDatabase) ExecuteScript(s *DXDatabaseScript) (err error) {
+	err = d.checkWritable()
+	if err != nil {
+		return err
+	}
 	_, err = s.Execute(d)
 	if err != nil {
 		return err
@@ -198,13 +285,23 @@ func (d *DXDatabase) GetNonSensitiveConnectionString() string {
 func (d *DXDatabase) GetConnectionString() (s string, err error) {
 	switch d.DatabaseType {
 	case database_type.PostgreSQL:
-		s = fmt.Sprintf("%s://%s:%s@%s/%s?%s", d.DatabaseType.String(), d.UserName, d.UserPassword, d.Address, d.DatabaseName, d.ConnectionOptions)
+		connectionOptions := d.ConnectionOptions
+		if (d.ApplicationName != "") && (!strings.Contains(connectionOptions, "application_name=")) {
+			if connectionOptions != "" {
+				connectionOptions = connectionOptions + "&"
+			}
+			connectionOptions = connectionOptions + "application_name=" + url.QueryEscape(d.ApplicationName)
+		}
+		s = fmt.Sprintf("%s://%s:%s@%s/%s?%s", d.DatabaseType.String(), d.UserName, d.UserPassword, d.Address, d.DatabaseName, connectionOptions)
 	case database_type.SQLServer:
 		host, port, err := net.SplitHostPort(d.Address)
 		if err != nil {
 			return "", err
 		}
 		s = fmt.Sprintf("server=%s;port=%s;user id=%s;password=%s;database=%s;encrypt=disable", host, port, d.UserName, d.UserPassword, d.DatabaseName)
+		if d.ApplicationName != "" {
+			s = s + ";app name=" + d.ApplicationName
+		}
 	case database_type.Oracle:
 		host, port, err := net.SplitHostPort(d.Address)
 		if err != nil {
@@ -229,104 +326,139 @@ func (d *DXDatabase) ApplyFromConfiguration() (err error) {
 		log.Log.Infof("Configuring to Database %s... start", d.NameId)
 		configurationData, ok := configuration.Manager.Configurations["storage"]
 		if !ok {
-			err = log.Log.PanicAndCreateErrorf("DXDatabase/ApplyFromConfiguration/1", "Storage configuration not found")
+			err = log.Log.ErrorAndCreateErrorf("Storage configuration not found")
 			return err
 		}
 		m := *(configurationData.Data)
 		databaseConfiguration, ok := m[d.NameId].(utils.JSON)
 		if !ok {
 			if d.MustConnected {
-				err := log.Log.FatalAndCreateErrorf("Database %s configuration not found", d.NameId)
+				err := log.Log.ErrorAndCreateErrorf("Database %s configuration not found", d.NameId)
 				return err
 			} else {
 				err := log.Log.WarnAndCreateErrorf("Manager is unusable, database %s configuration not found", d.NameId)
 				return err
 			}
 		}
-		n, ok := databaseConfiguration[`nameid`].(string)
-		if ok {
-			d.NameId = n
-		}
-		b, ok := databaseConfiguration[`must_connected`].(bool)
-		if ok {
-			d.MustConnected = b
+		err = d.ApplyConfigurationData(databaseConfiguration)
+		if err != nil {
+			return err
 		}
-		b, ok = databaseConfiguration[`is_connect_at_start`].(bool)
-		if ok {
-			d.IsConnectAtStart = b
+		log.Log.Infof("Configuring to Database %s... done", d.NameId)
+	}
+	return nil
+}
+
+// ApplyConfigurationData configures the database from a single database configuration map, the same fields as one
+// entry of the storage configuration.
+func (d *DXDatabase) ApplyConfigurationData(databaseConfiguration utils.JSON) (err error) {
+	n, ok := databaseConfiguration[`nameid`].(string)
+	if ok {
+		d.NameId = n
+	}
+	b, ok := databaseConfiguration[`must_connected`].(bool)
+	if ok {
+		d.MustConnected = b
+	}
+	b, ok = databaseConfiguration[`is_connect_at_start`].(bool)
+	if ok {
+		d.IsConnectAtStart = b
+	}
+	s, ok := databaseConfiguration[`database_type`].(string)
+	if !ok {
+		if d.MustConnected {
+			err := log.Log.ErrorAndCreateErrorf("Mandatory database_type field value in database %s configuration is not supported (%v)", d.NameId, s)
+			return err
+		} else {
+			err := log.Log.WarnAndCreateErrorf("configuration is unusable, mandatory database_type field value database %s configuration  is not supported (%v)", d.NameId, s)
+			return err
 		}
-		s, ok := databaseConfiguration[`database_type`].(string)
-		if !ok {
-			if d.MustConnected {
-				err := log.Log.FatalAndCreateErrorf("Mandatory database_type field value in database %s configuration is not supported (%v)", d.NameId, s)
-				return err
-			} else {
-				err := log.Log.WarnAndCreateErrorf("configuration is unusable, mandatory database_type field value database %s configuration  is not supported (%v)", d.NameId, s)
-				return err
-			}
+	}
+	d.DatabaseType = database_type.StringToDXDatabaseType(s)
+	if d.DatabaseType == database_type.UnknownDatabaseType {
+		if d.MustConnected {
+			err := log.Log.ErrorAndCreateErrorf("Mandatory value of database_type field of Database %s configuration is not supported (%s)", d.NameId, s)
+			return err
+		} else {
+			err := log.Log.WarnAndCreateErrorf("configuration is unusable, value of database_type field of database %s configuration is not supported (%s)", d.NameId, s)
+			return err
 		}
-		d.DatabaseType = database_type.StringToDXDatabaseType(s)
-		if d.DatabaseType == database_type.UnknownDatabaseType {
-			if d.MustConnected {
-				err := log.Log.FatalAndCreateErrorf("Mandatory value of database_type field of Database %s configuration is not supported (%s)", d.NameId, s)
-				return err
-			} else {
-				err := log.Log.WarnAndCreateErrorf("configuration is unusable, value of database_type field of database %s configuration is not supported (%s)", d.NameId, s)
-				return err
-			}
+	}
+	d.Address, ok = databaseConfiguration[`address`].(string)
+	if !ok {
+		if d.MustConnected {
+			err := log.Log.ErrorAndCreateErrorf("Mandatory address field in Database %s configuration not exist", d.NameId)
+			return err
+		} else {
+			err := log.Log.WarnAndCreateErrorf("configuration is unusable, mandatory address field in database %s configuration not exist", d.NameId)
+			return err
 		}
-		d.Address, ok = databaseConfiguration[`address`].(string)
-		if !ok {
-			if d.MustConnected {
-				err := log.Log.FatalAndCreateErrorf("Mandatory address field in Database %s configuration not exist", d.NameId)
-				return err
-			} else {
-				err := log.Log.WarnAndCreateErrorf("configuration is unusable, mandatory address field in database %s configuration not exist", d.NameId)
-				return err
-			}
+	}
+	d.UserName, ok = databaseConfiguration[`user_name`].(string)
+	if !ok {
+		if d.MustConnected {
+			err := log.Log.ErrorAndCreateErrorf("Mandatory user_name field in Database %s configuration not exist", d.NameId)
+			return err
+		} else {
+			err := log.Log.WarnAndCreateErrorf("configuration is unusable, mandatory user_name field in Database %s configuration not exist", d.NameId)
+			return err
 		}
-		d.UserName, ok = databaseConfiguration[`user_name`].(string)
-		if !ok {
-			if d.MustConnected {
-				err := log.Log.FatalAndCreateErrorf("Mandatory user_name field in Database %s configuration not exist", d.NameId)
-				return err
-			} else {
-				err := log.Log.WarnAndCreateErrorf("configuration is unusable, mandatory user_name field in Database %s configuration not exist", d.NameId)
-				return err
-			}
+	}
+	d.UserPassword, ok = databaseConfiguration[`user_password`].(string)
+	if !ok {
+		if d.MustConnected {
+			err := log.Log.ErrorAndCreateErrorf("Mandatory user_password field in Database %s configuration not exist", d.NameId)
+			return err
+		} else {
+			err := log.Log.WarnAndCreateErrorf("configuration is unusable, mandatory user_password field in Database %s configuration not exist", d.NameId)
+			return err
 		}
-		d.UserPassword, ok = databaseConfiguration[`user_password`].(string)
-		if !ok {
-			if d.MustConnected {
-				err := log.Log.FatalAndCreateErrorf("Mandatory user_password field in Database %s configuration not exist", d.NameId)
-				return err
-			} else {
-				err := log.Log.WarnAndCreateErrorf("configuration is unusable, mandatory user_password field in Database %s configuration not exist", d.NameId)
-				return err
-			}
+	}
+	d.DatabaseName, ok = databaseConfiguration[`database_name`].(string)
+	if !ok {
+		if d.MustConnected {
+			err := log.Log.ErrorAndCreateErrorf("Mandatory database_name field in Database %s configuration not exist", d.NameId)
+			return err
+		} else {
+			err := log.Log.WarnAndCreateErrorf("configuration is unusable, mandatory database_name field in Database %s configuration not exist", d.NameId)
+			return err
 		}
-		d.DatabaseName, ok = databaseConfiguration[`database_name`].(string)
+	}
+	d.CreateScriptFiles, _ = databaseConfiguration[`create_script_files`].([]string)
+	d.ConnectionOptions, _ = databaseConfiguration[`connection_options`].(string)
+	d.ApplicationName, ok = databaseConfiguration[`application_name`].(string)
+	if !ok {
+		d.ApplicationName = d.NameId
+	}
+	d.ReadOnly, _ = databaseConfiguration[`read_only`].(bool)
+	d.HealthcheckQuery, _ = databaseConfiguration[`healthcheck_query`].(string)
+	d.AllowTruncate, _ = databaseConfiguration[`allow_truncate`].(bool)
+	d.TraceAcquisition, _ = databaseConfiguration[`trace_acquisition`].(bool)
+	d.TraceAcquisitionThresholdMs = utilsJSON.GetNumberWithDefault(databaseConfiguration, `trace_acquisition_threshold_ms`, 100)
+	d.ReadTimeoutSec = utilsJSON.GetNumberWithDefault(databaseConfiguration, `read_timeout`, 0)
+	d.WriteTimeoutSec = utilsJSON.GetNumberWithDefault(databaseConfiguration, `write_timeout`, 0)
+	d.SlowQueryThresholdMs = utilsJSON.GetNumberWithDefault(databaseConfiguration, `slow_query_threshold_ms`, 0)
+	d.ConnectRetries = utilsJSON.GetNumberWithDefault(databaseConfiguration, `connect_retries`, 0)
+	connectRetryDelaySec := utilsJSON.GetNumberWithDefault(databaseConfiguration, `connect_retry_delay`, 1.0)
+	d.ConnectRetryDelay = time.Duration(connectRetryDelaySec * float64(time.Second))
+	d.OnConnectStatements = nil
+	onConnectStatements, _ := databaseConfiguration[`on_connect`].([]any)
+	for _, v := range onConnectStatements {
+		statement, ok := v.(string)
 		if !ok {
-			if d.MustConnected {
-				err := log.Log.FatalAndCreateErrorf("Mandatory database_name field in Database %s configuration not exist", d.NameId)
-				return err
-			} else {
-				err := log.Log.WarnAndCreateErrorf("configuration is unusable, mandatory database_name field in Database %s configuration not exist", d.NameId)
-				return err
-			}
-		}
-		d.CreateScriptFiles, _ = databaseConfiguration[`create_script_files`].([]string)
-		d.ConnectionOptions, _ = databaseConfiguration[`connection_options`].(string)
-
-		d.NonSensitiveConnectionString = d.GetNonSensitiveConnectionString()
-		d.ConnectionString, err = d.GetConnectionString()
-		if err != nil {
+			err := log.Log.WarnAndCreateErrorf("configuration is unusable, on_connect field in Database %s configuration must be a list of SQL statements", d.NameId)
 			return err
 		}
-		log.Log.Infof("Connecting to Database %s... done", d.NonSensitiveConnectionString)
-		d.IsConfigured = true
-		log.Log.Infof("Configuring to Database %s... done", d.NameId)
+		d.OnConnectStatements = append(d.OnConnectStatements, statement)
+	}
+
+	d.NonSensitiveConnectionString = d.GetNonSensitiveConnectionString()
+	d.ConnectionString, err = d.GetConnectionString()
+	if err != nil {
+		return err
 	}
+	log.Log.Infof("Connecting to Database %s... done", d.NonSensitiveConnectionString)
+	d.IsConfigured = true
 	return nil
 }
 
@@ -349,7 +481,12 @@ func (d *DXDatabase) CheckIsErrorBecauseDbNotExist(err error) bool {
 func (d *DXDatabase) Connect() (err error) {
 	if !d.Connected {
 		log.Log.Infof("Connecting to database %s/%s... start", d.NameId, d.NonSensitiveConnectionString)
-		connection, err := sqlx.Open(d.DatabaseType.Driver(), d.ConnectionString)
+		var connection *sqlx.DB
+		if (len(d.OnConnectStatements) > 0) || (d.OnConnect != nil) {
+			connection, err = d.openWithOnConnect(d.DatabaseType.Driver(), d.ConnectionString)
+		} else {
+			connection, err = sqlx.Open(d.DatabaseType.Driver(), d.ConnectionString)
+		}
 		if err != nil {
 			if d.MustConnected {
 				log.Log.Fatalf("Invalid parameters to open database %s/%s (%s)", d.NameId, d.NonSensitiveConnectionString, err.Error())
@@ -361,6 +498,12 @@ func (d *DXDatabase) Connect() (err error) {
 		}
 		d.Connection = connection
 		err = connection.Ping()
+		for attempt := 0; (err != nil) && (attempt < d.ConnectRetries); attempt++ {
+			delay := d.ConnectRetryDelay << attempt
+			log.Log.Warnf("Cannot connect and ping to database %s/%s, retry %d/%d in %v (%s)", d.NameId, d.NonSensitiveConnectionString, attempt+1, d.ConnectRetries, delay, err.Error())
+			time.Sleep(delay)
+			err = connection.Ping()
+		}
 		if err != nil {
 			if d.OnCannotConnect != nil {
 				d.OnCannotConnect(d, err)
@@ -373,7 +516,7 @@ func (d *DXDatabase) Connect() (err error) {
 				return err
 			}
 		}
-		d.Connected = true
+		d.setConnected(true)
 		log.Log.Infof("Connecting to database %s/%s... done CONNECTED", d.NameId, d.NonSensitiveConnectionString)
 	}
 	return nil
@@ -388,20 +531,26 @@ func (d *DXDatabase) Disconnect() (err error) {
 			return err
 		}
 		d.Connection = nil
-		d.Connected = false
+		d.setConnected(false)
 		log.Log.Infof("Disconnecting to database %s/%s... done DISCONNECTED", d.NameId, d.NonSensitiveConnectionString)
 	}
 	return nil
 }
 
 func (d *DXDatabase) Execute(statement string, parameters utils.JSON) (r any, err error) {
+	err = d.checkReadOnly(context.Background(), statement)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := d.StatementContext(context.Background(), statement)
+	defer cancel()
 	isDDL := utilsSql.IsDDL(statement)
 	if !isDDL {
 		query := pq.NewNamedParameterQuery(statement)
 		query.SetValuesFromMap(parameters)
 		s := query.GetParsedQuery()
 		p := query.GetParsedParameters()
-		r, err = d.Connection.Exec(s, p...)
+		r, err = d.Connection.ExecContext(ctx, s, p...)
 		return r, err
 	}
 	s := statement
@@ -418,7 +567,7 @@ func (d *DXDatabase) Execute(statement string, parameters utils.JSON) (r any, er
 		}
 		s = strings.Replace(s, `:`+strings.ToUpper(k), vs, -1)
 	}
-	r, err = d.Connection.Exec(s)
+	r, err = d.Connection.ExecContext(ctx, s)
 	if err != nil {
 		if d.Connected {
 			return nil, err
@@ -427,7 +576,7 @@ func (d *DXDatabase) Execute(statement string, parameters utils.JSON) (r any, er
 		if err != nil {
 			return nil, err
 		}
-		r, err = d.Connection.Exec(s)
+		r, err = d.Connection.ExecContext(ctx, s)
 		if err != nil {
 			return nil, err
 		}
@@ -435,6 +584,168 @@ func (d *DXDatabase) Execute(statement string, parameters utils.JSON) (r any, er
 	return r, err
 }
 
+type DXDatabaseRowCallback func(row utils.JSON) (err error)
+
+// StreamQuery runs query and calls callback for each row as it is read, without holding the whole result in memory. It
+// stops at the first callback error or when ctx is done.
+func (d *DXDatabase) StreamQuery(ctx context.Context, query string, args []any, callback DXDatabaseRowCallback) (err error) {
+	return d.streamQuery(ctx, query, args, nil, callback)
+}
+
+// QueryToChannel streams the rows of query to the returned channel, buffered with bufferSize rows, from a goroutine.
+// Both channels are closed when the rows are exhausted, on error, or when ctx is done; a terminal error, ctx.Err()
+// included, is sent on the error channel before. The connection is released as soon as streaming stops, the consumer
+// must drain the row channel or cancel ctx.
+func (d *DXDatabase) QueryToChannel(ctx context.Context, bufferSize int, query string, args ...any) (<-chan utils.JSON, <-chan error) {
+	rowChannel := make(chan utils.JSON, bufferSize)
+	errChannel := make(chan error, 1)
+	go func() {
+		defer close(rowChannel)
+		defer close(errChannel)
+		err := d.streamQuery(ctx, query, args, nil, func(row utils.JSON) error {
+			select {
+			case rowChannel <- row:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		if err != nil {
+			errChannel <- err
+		}
+	}()
+	return rowChannel, errChannel
+}
+
+// streamQuery is StreamQuery with onColumns called once with the result column names, before the first row.
+func (d *DXDatabase) streamQuery(ctx context.Context, query string, args []any, onColumns func(columns []string) error, callback DXDatabaseRowCallback) (err error) {
+	err = d.checkReadOnly(ctx, query)
+	if err != nil {
+		return err
+	}
+	ctx, err = d.runBeforeQueryHooks(ctx, query, args)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := d.StatementContext(ctx, query)
+	defer cancel()
+	start := time.Now()
+	defer func() {
+		d.runAfterQueryHooks(ctx, query, args, err, time.Since(start))
+	}()
+	session, release, err := d.acquireSession(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	boundArgs, err := d.bindArgs(args)
+	if err != nil {
+		return err
+	}
+	rows, err := session.QueryxContext(ctx, query, boundArgs...)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	scanFuncs, err := d.rowScanFuncs(rows)
+	if err != nil {
+		return err
+	}
+	driverName := d.Connection.DriverName()
+	if onColumns != nil {
+		columns, err := rows.Columns()
+		if err != nil {
+			return err
+		}
+		for i, c := range columns {
+			columns[i] = databaseProtectedUtils.DeformatIdentifier(c, driverName)
+		}
+		err = onColumns(columns)
+		if err != nil {
+			return err
+		}
+	}
+	for rows.Next() {
+		err = ctx.Err()
+		if err != nil {
+			return err
+		}
+		rowJSON := make(utils.JSON)
+		err = rows.MapScan(rowJSON)
+		if err != nil {
+			return err
+		}
+		err = applyScanFuncs(rowJSON, scanFuncs)
+		if err != nil {
+			return err
+		}
+		rowJSON = databaseProtectedUtils.DeformatKeys(rowJSON, driverName)
+		err = callback(rowJSON)
+		if err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// Exec runs an arbitrary statement on the managed connection, with the configured write/read timeout, error logging and
+// slow query logging. Driver errors are wrapped with their ErrDatabase sentinel, see translateError.
+func (d *DXDatabase) Exec(ctx context.Context, query string, args ...any) (r sql.Result, err error) {
+	if d.Connection == nil {
+		return nil, fmt.Errorf(`%w:%s`, ErrDatabaseNotConnected, d.NameId)
+	}
+	err = d.checkReadOnly(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	session, release, err := d.acquireSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	r, err = d.execOn(ctx, session, query, args)
+	return r, translateError(err)
+}
+
+// Query runs an arbitrary query on the managed connection, with error logging, slow query logging and the error
+// translation of Exec. No default timeout is applied because it would cancel the returned rows, the caller must close
+// them.
+func (d *DXDatabase) Query(ctx context.Context, query string, args ...any) (rows *sql.Rows, err error) {
+	if d.Connection == nil {
+		return nil, fmt.Errorf(`%w:%s`, ErrDatabaseNotConnected, d.NameId)
+	}
+	err = d.checkReadOnly(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	ctx, err = d.runBeforeQueryHooks(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
+	boundArgs, err := d.bindArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	rows, err = d.session(ctx).QueryContext(ctx, query, boundArgs...)
+	duration := time.Since(start)
+	d.runAfterQueryHooks(ctx, query, args, err, duration)
+	d.logStatement(duration, query, err)
+	return rows, translateError(err)
+}
+
+func (d *DXDatabase) logStatement(duration time.Duration, query string, err error) {
+	if err != nil {
+		log.Log.Errorf("Database %s error executing statement (%s) %s", d.NameId, err.Error(), query)
+		return
+	}
+	if (d.SlowQueryThresholdMs > 0) && (duration >= time.Duration(d.SlowQueryThresholdMs)*time.Millisecond) {
+		log.Log.Warnf("Database %s slow statement (%v) %s", d.NameId, duration, query)
+	}
+}
+
 func (d *DXDatabase) PropertyValue(key string) (value string, err error) {
 	//err = d.CheckConnectionAndReconnect()
 	//if err != nil {
@@ -451,6 +762,10 @@ func (d *DXDatabase) PropertyValue(key string) (value string, err error) {
 }
 
 func (d *DXDatabase) Insert(tableName string, fieldNameForRowId string, keyValues utils.JSON) (id int64, err error) {
+	err = d.checkWritable()
+	if err != nil {
+		return 0, err
+	}
 	//err = d.CheckConnectionAndReconnect()
 	//if err != nil {
 	//	return 0, err
@@ -459,6 +774,10 @@ func (d *DXDatabase) Insert(tableName string, fieldNameForRowId string, keyValue
 }
 
 func (d *DXDatabase) Update(tableName string, setKeyValues utils.JSON, whereKeyValues utils.JSON) (result sql.Result, err error) {
+	err = d.checkWritable()
+	if err != nil {
+		return nil, err
+	}
 	//err = d.CheckConnectionAndReconnect()
 	//if err != nil {
 	//	return nil, err
@@ -466,6 +785,63 @@ func (d *DXDatabase) Update(tableName string, setKeyValues utils.JSON, whereKeyV
 	return db.UpdateWhereKeyValues(d.Connection, tableName, setKeyValues, whereKeyValues)
 }
 
+// UpdateWhere sets the set columns of the rows of tableName matching every equality condition of where and returns the
+// number of rows updated. An empty where is rejected with ErrDatabaseEmptyWhere, an empty set with ErrDatabaseEmptySet.
+func (d *DXDatabase) UpdateWhere(tableName string, set utils.JSON, where utils.JSON) (rowsAffected int64, err error) {
+	if len(where) == 0 {
+		return 0, fmt.Errorf(`%w:%s`, ErrDatabaseEmptyWhere, tableName)
+	}
+	if len(set) == 0 {
+		return 0, fmt.Errorf(`%w:%s`, ErrDatabaseEmptySet, tableName)
+	}
+	err = d.checkWritable()
+	if err != nil {
+		return 0, err
+	}
+	if d.Connection == nil {
+		return 0, fmt.Errorf(`%w:%s`, ErrDatabaseNotConnected, d.NameId)
+	}
+	r, err := db.UpdateWhereKeyValues(d.Connection, tableName, set, where)
+	if err != nil {
+		log.Log.Errorf("Database %s cannot update %s (%s)", d.NameId, tableName, err.Error())
+		return 0, err
+	}
+	return r.RowsAffected()
+}
+
+// DeleteWhere deletes the rows of tableName matching every equality condition of where and returns the number of rows
+// deleted. An empty where is rejected with ErrDatabaseEmptyWhere, use DeleteAll to empty a table on purpose.
+func (d *DXDatabase) DeleteWhere(tableName string, where utils.JSON) (rowsAffected int64, err error) {
+	if len(where) == 0 {
+		return 0, fmt.Errorf(`%w:%s`, ErrDatabaseEmptyWhere, tableName)
+	}
+	err = d.checkWritable()
+	if err != nil {
+		return 0, err
+	}
+	if d.Connection == nil {
+		return 0, fmt.Errorf(`%w:%s`, ErrDatabaseNotConnected, d.NameId)
+	}
+	r, err := db.DeleteWhereKeyValues(d.Connection, tableName, where)
+	if err != nil {
+		log.Log.Errorf("Database %s cannot delete from %s (%s)", d.NameId, tableName, err.Error())
+		return 0, err
+	}
+	return r.RowsAffected()
+}
+
+// DeleteAll deletes every row of tableName and returns the number of rows deleted.
+func (d *DXDatabase) DeleteAll(tableName string) (rowsAffected int64, err error) {
+	if !maintenanceTableNameRegexp.MatchString(tableName) {
+		return 0, fmt.Errorf(`%w:%s`, ErrDatabaseInvalidIdentifier, tableName)
+	}
+	r, err := d.Exec(context.Background(), `DELETE FROM `+tableName)
+	if err != nil {
+		return 0, err
+	}
+	return r.RowsAffected()
+}
+
 func (d *DXDatabase) ShouldSelectOne(tableName string, whereAndFieldNameValues utils.JSON, orderbyFieldNameDirections map[string]string) (
 	rowsInfo *db.RowsInfo, resultData utils.JSON, err error) {
 	//err = d.CheckConnectionAndReconnect()
@@ -514,6 +890,10 @@ func (d *DXDatabase) ExecuteFile(filename string) (r sql.Result, err error) {
 		}
 	}()
 
+	err = d.checkWritable()
+	if err != nil {
+		return nil, err
+	}
 	err = d.CheckConnectionAndReconnect()
 	if err != nil {
 		return nil, err
@@ -557,6 +937,10 @@ func (d *DXDatabase) ExecuteFile(filename string) (r sql.Result, err error) {
 }
 
 func (d *DXDatabase) ExecuteCreateScripts() (rs []sql.Result, err error) {
+	err = d.checkWritable()
+	if err != nil {
+		return nil, err
+	}
 	if !d.Connected {
 		err = d.Connect()
 		if err != nil {
@@ -580,6 +964,36 @@ func (d *DXDatabase) ExecuteCreateScripts() (rs []sql.Result, err error) {
 	return rs, nil
 }
 
+// Begin starts a transaction and returns its handle, for transactions that do not fit the Tx callback, like one
+// spanning several request handlers. The caller must end it with Commit or Rollback; a handle garbage collected while
+// still open is logged as a leak and rolled back.
+func (d *DXDatabase) Begin(ctx context.Context) (dtx *DXDatabaseTx, err error) {
+	if d.Connection == nil {
+		return nil, fmt.Errorf(`%w:%s`, ErrDatabaseNotConnected, d.NameId)
+	}
+	return d.begin(ctx, nil, &log.Log)
+}
+
+func (d *DXDatabase) begin(ctx context.Context, txOptions *sql.TxOptions, l *log.DXLog) (dtx *DXDatabaseTx, err error) {
+	tx, err := d.Connection.BeginTxx(ctx, d.readOnlyTxOptions(txOptions))
+	if err != nil {
+		return nil, err
+	}
+	dtx = &DXDatabaseTx{
+		Tx:  tx,
+		Log: l,
+	}
+	nameId := d.NameId
+	runtime.SetFinalizer(dtx, func(dtx *DXDatabaseTx) {
+		if dtx.finished.Load() {
+			return
+		}
+		dtx.Log.Warnf("TX_LEAK: transaction of database %s garbage collected without Commit or Rollback, rolling back", nameId)
+		_ = dtx.Tx.Rollback()
+	})
+	return dtx, nil
+}
+
 func (d *DXDatabase) Tx(log *log.DXLog, isolationLevel sql.IsolationLevel, callback DXDatabaseTxCallback) (err error) {
 	//err = d.CheckConnectionAndReconnect()
 	//if err != nil {
@@ -615,31 +1029,27 @@ func (d *DXDatabase) Tx(log *log.DXLog, isolationLevel sql.IsolationLevel, callb
 		return nil
 	}
 
-	tx, err := d.Connection.BeginTxx(log.Context, &sql.TxOptions{
+	dtx, err := d.begin(log.Context, &sql.TxOptions{
 		Isolation: isolationLevel,
 		ReadOnly:  false,
-	})
+	}, log)
 	if err != nil {
 		log.Error(err.Error())
 		return err
 	}
-	dtx := &DXDatabaseTx{
-		Tx:  tx,
-		Log: log,
-	}
 	err = callback(dtx)
 	if err != nil {
 		log.Errorf(`TX_ERROR_IN_CALLBACK: (%v)`, err.Error())
-		errTx := tx.Rollback()
+		errTx := dtx.rollback()
 		if errTx != nil {
 			log.Errorf(`SHOULD_NOT_HAPPEN:ERROR_IN_ROLLBACK(%v)`, errTx.Error())
 		}
 		return err
 	}
-	err = dtx.Tx.Commit()
+	err = dtx.commit()
 	if err != nil {
 		log.Errorf(`TX_ERROR_IN_COMMIT: (%v)`, err.Error())
-		errTx := tx.Rollback()
+		errTx := dtx.rollback()
 		if errTx != nil {
 			log.Errorf(`ErrorInCommitRollback: (%v)`, errTx.Error())
 		}
@@ -649,6 +1059,105 @@ func (d *DXDatabase) Tx(log *log.DXLog, isolationLevel sql.IsolationLevel, callb
 	return nil
 }
 
+// ReadTx runs callback inside a read-only transaction, so any accidental mutation is rejected by the database. The
+// transaction is always rolled back because there is nothing to commit.
+func (d *DXDatabase) ReadTx(callback DXDatabaseTxCallback) (err error) {
+	err = d.CheckConnectionAndReconnect()
+	if err != nil {
+		return err
+	}
+	txOptions := &sql.TxOptions{
+		ReadOnly: true,
+	}
+	dtx, err := d.begin(context.Background(), txOptions, &log.Log)
+	if err != nil {
+		log.Log.Error(err.Error())
+		return err
+	}
+	defer func() {
+		errTx := dtx.rollback()
+		if (errTx != nil) && (!errors.Is(errTx, sql.ErrTxDone)) {
+			log.Log.Errorf(`SHOULD_NOT_HAPPEN:ERROR_IN_ROLLBACK(%v)`, errTx.Error())
+		}
+	}()
+	err = callback(dtx)
+	if err != nil {
+		log.Log.Errorf(`TX_ERROR_IN_CALLBACK: (%v)`, err.Error())
+		return err
+	}
+	return nil
+}
+
+const DXDatabaseTxSerializationFailureRetryMax = 3
+
+// TxWithOptions runs callback inside a transaction begun with txOptions, committing on success and rolling back on
+// error. A PostgreSQL SERIALIZABLE transaction failing with serialization_failure (40001) is retried from the start up
+// to DXDatabaseTxSerializationFailureRetryMax times, so callback must be safe to run again.
+func (d *DXDatabase) TxWithOptions(txOptions *sql.TxOptions, callback DXDatabaseTxCallback) (err error) {
+	if txOptions == nil {
+		txOptions = &sql.TxOptions{}
+	}
+	tryCount := 0
+	for {
+		err = d.txWithOptions(txOptions, callback)
+		if err == nil {
+			return nil
+		}
+		if (txOptions.Isolation != sql.LevelSerializable) || (!d.IsSerializationFailure(err)) || (tryCount >= DXDatabaseTxSerializationFailureRetryMax) {
+			return err
+		}
+		tryCount++
+		log.Log.Warnf("TX_SERIALIZATION_FAILURE_RETRY:%s:%d (%v)", d.NameId, tryCount, err.Error())
+	}
+}
+
+func (d *DXDatabase) txWithOptions(txOptions *sql.TxOptions, callback DXDatabaseTxCallback) (err error) {
+	err = d.CheckConnectionAndReconnect()
+	if err != nil {
+		return err
+	}
+	effectiveTxOptions := *txOptions
+	switch d.Connection.DriverName() {
+	case "oracle":
+		effectiveTxOptions.Isolation = sql.LevelDefault
+	}
+	dtx, err := d.begin(context.Background(), &effectiveTxOptions, &log.Log)
+	if err != nil {
+		log.Log.Error(err.Error())
+		return err
+	}
+	err = callback(dtx)
+	if err != nil {
+		log.Log.Errorf(`TX_ERROR_IN_CALLBACK: (%v)`, err.Error())
+		errTx := dtx.rollback()
+		if errTx != nil {
+			log.Log.Errorf(`SHOULD_NOT_HAPPEN:ERROR_IN_ROLLBACK(%v)`, errTx.Error())
+		}
+		return err
+	}
+	err = dtx.commit()
+	if err != nil {
+		log.Log.Errorf(`TX_ERROR_IN_COMMIT: (%v)`, err.Error())
+		errTx := dtx.rollback()
+		if errTx != nil {
+			log.Log.Errorf(`ErrorInCommitRollback: (%v)`, errTx.Error())
+		}
+		return err
+	}
+	return nil
+}
+
+func (d *DXDatabase) IsSerializationFailure(err error) bool {
+	switch d.DatabaseType {
+	case database_type.PostgreSQL:
+		var pqErr *libPq.Error
+		if errors.As(err, &pqErr) {
+			return pqErr.Code == "40001"
+		}
+	}
+	return false
+}
+
 func (dtx *DXDatabaseTx) SelectOne(tableName string, fieldNames []string, whereAndFieldNameValues utils.JSON, joinSQLPart any,
 	orderbyFieldNameDirections map[string]string, forUpdatePart any) (rowsInfo *db.RowsInfo, r utils.JSON, err error) {
 	return dbtx.TxSelectOne(dtx.Log, false, dtx.Tx, tableName, fieldNames, whereAndFieldNameValues, joinSQLPart, orderbyFieldNameDirections, forUpdatePart)
@@ -0,0 +1,43 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/donnyhardyanto/dxlib/log"
+)
+
+// traceAcquisition logs at debug level, with the pool stats of that moment, an acquisition that waited at least
+// trace_acquisition_threshold_ms.
+func (d *DXDatabase) traceAcquisition(duration time.Duration) {
+	if !d.TraceAcquisition || (duration < time.Duration(d.TraceAcquisitionThresholdMs)*time.Millisecond) {
+		return
+	}
+	stats := d.Connection.Stats()
+	log.Log.Debugf("Database %s waited %v to acquire a connection (open %d/%d, in use %d, idle %d, wait count %d, wait duration %v)",
+		d.NameId, duration, stats.OpenConnections, stats.MaxOpenConnections, stats.InUse, stats.Idle, stats.WaitCount, stats.WaitDuration)
+}
+
+// acquireSession returns the session for ctx like session. With trace_acquisition enabled and no tenant connection, a
+// connection is acquired explicitly to time the wait, release returns it to the pool. Disabled, it costs nothing more
+// than session.
+func (d *DXDatabase) acquireSession(ctx context.Context) (session dxDatabaseSession, release func(), err error) {
+	session = d.session(ctx)
+	if !d.TraceAcquisition {
+		return session, func() {}, nil
+	}
+	if _, isPool := session.(*sqlx.DB); !isPool {
+		return session, func() {}, nil
+	}
+	start := time.Now()
+	conn, err := d.Connection.Connx(ctx)
+	d.traceAcquisition(time.Since(start))
+	if err != nil {
+		return nil, nil, err
+	}
+	return conn, func() {
+		_ = conn.Close()
+	}, nil
+}
@@ -0,0 +1,60 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"sync"
+
+	"github.com/donnyhardyanto/dxlib/log"
+)
+
+// TryAdvisoryLock tries to take the PostgreSQL session advisory lock key without waiting. Advisory locks belong to a
+// session, so a dedicated connection is held out of the pool until release is called; release unlocks and returns it,
+// and is safe to call more than once. When the lock is taken by another session acquired is false and release is nil.
+func (d *DXDatabase) TryAdvisoryLock(ctx context.Context, key int64) (acquired bool, release func() error, err error) {
+	if d.Connection == nil {
+		return false, nil, fmt.Errorf(`%w:%s`, ErrDatabaseNotConnected, d.NameId)
+	}
+	if d.Connection.DriverName() != "postgres" {
+		err = log.Log.ErrorAndCreateErrorf("TryAdvisoryLock is not supported for database driver %s", d.Connection.DriverName())
+		return false, nil, err
+	}
+	conn, err := d.Connection.Connx(ctx)
+	if err != nil {
+		log.Log.Errorf("Database %s cannot acquire connection for advisory lock %d (%s)", d.NameId, key, err.Error())
+		return false, nil, err
+	}
+	err = conn.QueryRowxContext(ctx, `SELECT pg_try_advisory_lock($1)`, key).Scan(&acquired)
+	if err != nil {
+		log.Log.Errorf("Database %s cannot try advisory lock %d (%s)", d.NameId, key, err.Error())
+		_ = conn.Close()
+		return false, nil, err
+	}
+	if !acquired {
+		_ = conn.Close()
+		return false, nil, nil
+	}
+	var releaseOnce sync.Once
+	release = func() (err error) {
+		releaseOnce.Do(func() {
+			var unlocked bool
+			err = conn.QueryRowxContext(context.Background(), `SELECT pg_advisory_unlock($1)`, key).Scan(&unlocked)
+			if (err == nil) && !unlocked {
+				log.Log.Warnf("Database %s advisory lock %d was not held at release", d.NameId, key)
+			}
+			if err != nil {
+				log.Log.Errorf("Database %s cannot release advisory lock %d, discarding the connection (%s)", d.NameId, key, err.Error())
+				_ = conn.Raw(func(any) error {
+					return driver.ErrBadConn
+				})
+			}
+			closeErr := conn.Close()
+			if err == nil {
+				err = closeErr
+			}
+		})
+		return err
+	}
+	return true, release, nil
+}
@@ -0,0 +1,35 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/donnyhardyanto/dxlib/database/protected/db"
+	"github.com/donnyhardyanto/dxlib/utils"
+)
+
+// CountDistinct returns the number of distinct non-null values of column in the rows of tableName matching where, see
+// NewSelectSQLExpression for the where entries.
+func (d *DXDatabase) CountDistinct(tableName string, column string, where utils.JSON) (count int64, err error) {
+	if !maintenanceTableNameRegexp.MatchString(tableName) {
+		return 0, fmt.Errorf(`%w:%s`, ErrDatabaseInvalidIdentifier, tableName)
+	}
+	if !maintenanceTableNameRegexp.MatchString(column) {
+		return 0, fmt.Errorf(`%w:%s`, ErrDatabaseInvalidIdentifier, column)
+	}
+	se := db.NewSelectSQLExpression(tableName, []string{`COUNT(DISTINCT ` + column + `)`}, where)
+	rows, err := d.QueryExpression(context.Background(), se)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	if rows.Next() {
+		err = rows.Scan(&count)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return count, rows.Err()
+}
@@ -0,0 +1,194 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/donnyhardyanto/dxlib/log"
+	"github.com/donnyhardyanto/dxlib/utils"
+)
+
+func bulkPlaceholder(driverName string, index int) string {
+	switch driverName {
+	case "postgres":
+		return `$` + strconv.Itoa(index)
+	case "sqlserver":
+		return `@p` + strconv.Itoa(index)
+	case "oracle":
+		return `:` + strconv.Itoa(index)
+	default:
+		return `?`
+	}
+}
+
+// bulkMaxParameters is the number of bind parameters one statement of driverName may use: PostgreSQL and MySQL allow
+// 65535, SQL Server 2100 and an Oracle IN list 1000 expressions. Some room is left for the non-list parameters.
+func bulkMaxParameters(driverName string) int {
+	switch driverName {
+	case "sqlserver":
+		return 2000
+	case "oracle":
+		return 1000
+	default:
+		return 65000
+	}
+}
+
+// BulkUpdate applies updates, each holding keyColumn and updateColumns values, to tableName in one statement. PostgreSQL
+// and SQL Server join the table with a VALUES list, other databases use one CASE expression per column. Rows missing
+// from updates are left untouched. tableName, keyColumn and updateColumns are validated as plain identifiers.
+func (d *DXDatabase) BulkUpdate(tableName string, keyColumn string, updates []utils.JSON, updateColumns []string) (rowsAffected int64, err error) {
+	if len(updates) == 0 {
+		return 0, nil
+	}
+	if len(updateColumns) == 0 {
+		return 0, errors.New(`BULK_UPDATE_NO_UPDATE_COLUMNS:` + tableName)
+	}
+	for _, identifier := range append([]string{tableName, keyColumn}, updateColumns...) {
+		if !maintenanceTableNameRegexp.MatchString(identifier) {
+			return 0, fmt.Errorf(`%w:%s`, ErrDatabaseInvalidIdentifier, identifier)
+		}
+	}
+	err = d.CheckConnectionAndReconnect()
+	if err != nil {
+		return 0, err
+	}
+	for i, update := range updates {
+		if _, ok := update[keyColumn]; !ok {
+			return 0, errors.New(`BULK_UPDATE_KEY_NOT_FOUND:` + strconv.Itoa(i) + `:` + keyColumn)
+		}
+	}
+	driverName := d.Connection.DriverName()
+	s := ``
+	args := []any{}
+	switch driverName {
+	case "postgres":
+		columnTypes := map[string]string{}
+		columns, err := d.Columns(tableName)
+		if err != nil {
+			return 0, err
+		}
+		for _, c := range columns {
+			columnTypes[c.Name] = c.DataType
+		}
+		valueColumns := append([]string{keyColumn}, updateColumns...)
+		valueRows := []string{}
+		for _, update := range updates {
+			placeholders := []string{}
+			for _, c := range valueColumns {
+				args = append(args, update[c])
+				columnType, ok := columnTypes[strings.ToLower(c)]
+				if !ok {
+					return 0, errors.New(`BULK_UPDATE_COLUMN_NOT_FOUND:` + tableName + `.` + c)
+				}
+				placeholder := bulkPlaceholder(driverName, len(args))
+				// VALUES parameters are untyped in PostgreSQL, cast them to the column type unless it has no usable name
+				if (columnType != `USER-DEFINED`) && (columnType != `ARRAY`) {
+					placeholder = `cast(` + placeholder + ` as ` + columnType + `)`
+				}
+				placeholders = append(placeholders, placeholder)
+			}
+			valueRows = append(valueRows, `(`+strings.Join(placeholders, `, `)+`)`)
+		}
+		sets := []string{}
+		for _, c := range updateColumns {
+			sets = append(sets, c+` = v.`+c)
+		}
+		s = `update ` + tableName + ` as t set ` + strings.Join(sets, `, `) + ` from (values ` + strings.Join(valueRows, `, `) + `) as v(` + strings.Join(valueColumns, `, `) + `) where t.` + keyColumn + ` = v.` + keyColumn
+	case "sqlserver":
+		valueColumns := append([]string{keyColumn}, updateColumns...)
+		valueRows := []string{}
+		for _, update := range updates {
+			placeholders := []string{}
+			for _, c := range valueColumns {
+				args = append(args, update[c])
+				placeholders = append(placeholders, bulkPlaceholder(driverName, len(args)))
+			}
+			valueRows = append(valueRows, `(`+strings.Join(placeholders, `, `)+`)`)
+		}
+		sets := []string{}
+		for _, c := range updateColumns {
+			sets = append(sets, `t.`+c+` = v.`+c)
+		}
+		s = `update t set ` + strings.Join(sets, `, `) + ` from ` + tableName + ` as t join (values ` + strings.Join(valueRows, `, `) + `) as v(` + strings.Join(valueColumns, `, `) + `) on t.` + keyColumn + ` = v.` + keyColumn
+	default:
+		sets := []string{}
+		for _, c := range updateColumns {
+			cases := c + ` = case ` + keyColumn
+			for _, update := range updates {
+				args = append(args, update[keyColumn])
+				cases = cases + ` when ` + bulkPlaceholder(driverName, len(args))
+				args = append(args, update[c])
+				cases = cases + ` then ` + bulkPlaceholder(driverName, len(args))
+			}
+			sets = append(sets, cases+` else `+c+` end`)
+		}
+		keys := []string{}
+		for _, update := range updates {
+			args = append(args, update[keyColumn])
+			keys = append(keys, bulkPlaceholder(driverName, len(args)))
+		}
+		s = `update ` + tableName + ` set ` + strings.Join(sets, `, `) + ` where ` + keyColumn + ` in (` + strings.Join(keys, `, `) + `)`
+	}
+	r, err := d.Exec(context.Background(), s, args...)
+	if err != nil {
+		return 0, err
+	}
+	return r.RowsAffected()
+}
+
+// BulkDeleteByKeys deletes the rows of tableName whose keyColumn is in keys with DELETE ... WHERE keyColumn IN (...),
+// split into chunks within the parameter limit of the driver, and returns the total number of rows deleted. All chunks
+// run in one transaction, when one fails none of the rows are deleted.
+func (d *DXDatabase) BulkDeleteByKeys(tableName string, keyColumn string, keys []any) (rowsAffected int64, err error) {
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	for _, identifier := range []string{tableName, keyColumn} {
+		if !maintenanceTableNameRegexp.MatchString(identifier) {
+			return 0, fmt.Errorf(`%w:%s`, ErrDatabaseInvalidIdentifier, identifier)
+		}
+	}
+	err = d.CheckConnectionAndReconnect()
+	if err != nil {
+		return 0, err
+	}
+	err = d.checkWritable()
+	if err != nil {
+		return 0, err
+	}
+	ctx := context.Background()
+	dtx, err := d.begin(ctx, nil, &log.Log)
+	if err != nil {
+		return 0, err
+	}
+	driverName := d.Connection.DriverName()
+	chunkSize := bulkMaxParameters(driverName)
+	for start := 0; start < len(keys); start += chunkSize {
+		end := min(start+chunkSize, len(keys))
+		placeholders := make([]string, 0, end-start)
+		for i := range keys[start:end] {
+			placeholders = append(placeholders, bulkPlaceholder(driverName, i+1))
+		}
+		s := `delete from ` + tableName + ` where ` + keyColumn + ` in (` + strings.Join(placeholders, `, `) + `)`
+		r, err := d.execOn(ctx, dtx.Tx, s, keys[start:end])
+		if err != nil {
+			_ = dtx.rollback()
+			return 0, translateError(err)
+		}
+		n, err := r.RowsAffected()
+		if err != nil {
+			_ = dtx.rollback()
+			return 0, err
+		}
+		rowsAffected += n
+	}
+	err = dtx.commit()
+	if err != nil {
+		return 0, err
+	}
+	return rowsAffected, nil
+}
@@ -0,0 +1,146 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/donnyhardyanto/dxlib/log"
+)
+
+var ErrDatabaseTableNotFound = errors.New(`TABLE_NOT_FOUND`)
+
+type DXColumn struct {
+	Name         string
+	DataType     string
+	UDTName      string
+	IsNullable   bool
+	Default      string
+	HasDefault   bool
+	OrdinalIndex int
+}
+
+// Columns returns the columns of tableName in ordinal order, read from information_schema (PostgreSQL, MySQL,
+// SQL Server) or all_tab_columns (Oracle). tableName may be qualified as schema.table, otherwise the current schema of
+// the connection is used. UDTName is the underlying type name on PostgreSQL, like the enum or geometry type of a
+// USER-DEFINED column, and DataType elsewhere.
+func (d *DXDatabase) Columns(tableName string) (columns []DXColumn, err error) {
+	err = d.CheckConnectionAndReconnect()
+	if err != nil {
+		return nil, err
+	}
+	schemaName := ``
+	if i := strings.LastIndex(tableName, `.`); i >= 0 {
+		schemaName = tableName[:i]
+		tableName = tableName[i+1:]
+	}
+	query := ``
+	args := []any{}
+	driverName := d.Connection.DriverName()
+	switch driverName {
+	case "postgres":
+		query = `select column_name, data_type, is_nullable, column_default, udt_name from information_schema.columns where table_schema = current_schema() and table_name = $1 order by ordinal_position`
+		args = append(args, tableName)
+		if schemaName != `` {
+			query = `select column_name, data_type, is_nullable, column_default, udt_name from information_schema.columns where table_schema = $2 and table_name = $1 order by ordinal_position`
+			args = append(args, schemaName)
+		}
+	case "mysql":
+		query = `select column_name, data_type, is_nullable, column_default, data_type from information_schema.columns where table_schema = database() and table_name = ? order by ordinal_position`
+		args = append(args, tableName)
+		if schemaName != `` {
+			query = `select column_name, data_type, is_nullable, column_default, data_type from information_schema.columns where table_name = ? and table_schema = ? order by ordinal_position`
+			args = append(args, schemaName)
+		}
+	case "sqlserver":
+		query = `select column_name, data_type, is_nullable, column_default, data_type from information_schema.columns where table_schema = schema_name() and table_name = @p1 order by ordinal_position`
+		args = append(args, tableName)
+		if schemaName != `` {
+			query = `select column_name, data_type, is_nullable, column_default, data_type from information_schema.columns where table_schema = @p2 and table_name = @p1 order by ordinal_position`
+			args = append(args, schemaName)
+		}
+	case "oracle":
+		query = `select column_name, data_type, nullable, data_default, data_type from user_tab_columns where table_name = :1 order by column_id`
+		args = append(args, strings.ToUpper(tableName))
+		if schemaName != `` {
+			query = `select column_name, data_type, nullable, data_default, data_type from all_tab_columns where table_name = :1 and owner = :2 order by column_id`
+			args = append(args, strings.ToUpper(schemaName))
+		}
+	default:
+		err = errors.New(`UNSUPPORTED_DATABASE_COLUMNS:` + driverName)
+		return nil, err
+	}
+
+	rows, err := d.Connection.QueryContext(context.Background(), query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	columns = []DXColumn{}
+	for rows.Next() {
+		var name, dataType, isNullable, udtName string
+		var defaultValue sql.NullString
+		err = rows.Scan(&name, &dataType, &isNullable, &defaultValue, &udtName)
+		if err != nil {
+			return nil, err
+		}
+		columns = append(columns, DXColumn{
+			Name:         strings.ToLower(name),
+			DataType:     dataType,
+			UDTName:      udtName,
+			IsNullable:   (strings.ToUpper(isNullable) == `YES`) || (strings.ToUpper(isNullable) == `Y`),
+			Default:      defaultValue.String,
+			HasDefault:   defaultValue.Valid,
+			OrdinalIndex: len(columns),
+		})
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	if len(columns) == 0 {
+		err = fmt.Errorf(`%w:%s`, ErrDatabaseTableNotFound, tableName)
+		return nil, err
+	}
+	return columns, nil
+}
+
+// DXDatabaseSchemaSpec lists the expected columns per table name, a table with no columns only has to exist.
+type DXDatabaseSchemaSpec struct {
+	Tables map[string][]string
+}
+
+// AssertSchema checks that every table and column in spec exists and returns one error listing everything missing, to
+// be called right after Connect so a schema and code mismatch fails at boot.
+func (d *DXDatabase) AssertSchema(spec DXDatabaseSchemaSpec) (err error) {
+	missingErrors := []error{}
+	for tableName, columnNames := range spec.Tables {
+		columns, err := d.Columns(tableName)
+		if err != nil {
+			if errors.Is(err, ErrDatabaseTableNotFound) {
+				missingErrors = append(missingErrors, err)
+				continue
+			}
+			return err
+		}
+		existingColumnNames := map[string]bool{}
+		for _, c := range columns {
+			existingColumnNames[c.Name] = true
+		}
+		for _, columnName := range columnNames {
+			if !existingColumnNames[strings.ToLower(columnName)] {
+				missingErrors = append(missingErrors, fmt.Errorf(`COLUMN_NOT_FOUND:%s.%s`, tableName, columnName))
+			}
+		}
+	}
+	if len(missingErrors) > 0 {
+		err = errors.Join(missingErrors...)
+		log.Log.Errorf("Database %s schema assertion failed (%s)", d.NameId, err.Error())
+		return err
+	}
+	return nil
+}
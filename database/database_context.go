@@ -0,0 +1,44 @@
+package database
+
+import "context"
+
+// Context values shared by the query hooks, audit, tracing and multi-tenant features. Every feature reads the actor,
+// tenant and request id through these helpers instead of defining its own keys.
+
+type actorContextKey struct{}
+
+type tenantContextKey struct{}
+
+type requestIDContextKey struct{}
+
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorContextKey{}, actor)
+}
+
+// ActorFromContext returns the actor set by WithActor, or "" when there is none.
+func ActorFromContext(ctx context.Context) string {
+	actor, _ := ctx.Value(actorContextKey{}).(string)
+	return actor
+}
+
+// WithTenant only records the tenant in ctx, DXDatabase.WithTenant also switches the connection to its schema and
+// records it the same way.
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantContextKey{}, tenant)
+}
+
+// TenantFromContext returns the tenant set by WithTenant, or "" when there is none.
+func TenantFromContext(ctx context.Context) string {
+	tenant, _ := ctx.Value(tenantContextKey{}).(string)
+	return tenant
+}
+
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDContextKey{}, requestID)
+}
+
+// RequestIDFromContext returns the request id set by WithRequestID, or "" when there is none.
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDContextKey{}).(string)
+	return requestID
+}
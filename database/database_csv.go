@@ -0,0 +1,56 @@
+package database
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/donnyhardyanto/dxlib/utils"
+)
+
+func csvFormatValue(v any) string {
+	switch t := v.(type) {
+	case nil:
+		return ``
+	case []byte:
+		return string(t)
+	case time.Time:
+		return t.Format(time.RFC3339Nano)
+	default:
+		return fmt.Sprint(t)
+	}
+}
+
+// QueryToCSV streams the result of query to w as CSV, a header row of column names followed by one record per row, and
+// returns the number of data rows written.
+func (d *DXDatabase) QueryToCSV(ctx context.Context, w io.Writer, query string, args ...any) (rowCount int64, err error) {
+	csvWriter := csv.NewWriter(w)
+	var columns []string
+	record := []string{}
+	err = d.streamQuery(ctx, query, args, func(c []string) error {
+		columns = c
+		record = make([]string, len(columns))
+		return csvWriter.Write(columns)
+	}, func(row utils.JSON) error {
+		for i, c := range columns {
+			record[i] = csvFormatValue(row[c])
+		}
+		err := csvWriter.Write(record)
+		if err != nil {
+			return err
+		}
+		rowCount++
+		return nil
+	})
+	csvWriter.Flush()
+	if err != nil {
+		return rowCount, err
+	}
+	err = csvWriter.Error()
+	if err != nil {
+		return rowCount, err
+	}
+	return rowCount, nil
+}
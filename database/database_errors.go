@@ -0,0 +1,95 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/go-sql-driver/mysql"
+	libPq "github.com/lib/pq"
+	mssql "github.com/microsoft/go-mssqldb"
+	"github.com/sijms/go-ora/v2/network"
+)
+
+var ErrDatabaseUniqueViolation = errors.New(`DATABASE_UNIQUE_VIOLATION`)
+var ErrDatabaseForeignKeyViolation = errors.New(`DATABASE_FOREIGN_KEY_VIOLATION`)
+var ErrDatabaseNotNullViolation = errors.New(`DATABASE_NOT_NULL_VIOLATION`)
+var ErrDatabaseSerializationFailure = errors.New(`DATABASE_SERIALIZATION_FAILURE`)
+var ErrDatabaseDeadlock = errors.New(`DATABASE_DEADLOCK`)
+
+// driverErrorSentinel maps the driver specific error in err to one of the ErrDatabase sentinels, nil when there is
+// none for it.
+func driverErrorSentinel(err error) error {
+	var pqErr *libPq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code {
+		case "23505":
+			return ErrDatabaseUniqueViolation
+		case "23503":
+			return ErrDatabaseForeignKeyViolation
+		case "23502":
+			return ErrDatabaseNotNullViolation
+		case "40001":
+			return ErrDatabaseSerializationFailure
+		case "40P01":
+			return ErrDatabaseDeadlock
+		}
+		return nil
+	}
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		switch mysqlErr.Number {
+		case 1062:
+			return ErrDatabaseUniqueViolation
+		case 1451, 1452:
+			return ErrDatabaseForeignKeyViolation
+		case 1048:
+			return ErrDatabaseNotNullViolation
+		case 1213:
+			return ErrDatabaseDeadlock
+		}
+		return nil
+	}
+	var mssqlErr mssql.Error
+	if errors.As(err, &mssqlErr) {
+		switch mssqlErr.Number {
+		case 2627, 2601:
+			return ErrDatabaseUniqueViolation
+		case 547:
+			return ErrDatabaseForeignKeyViolation
+		case 515:
+			return ErrDatabaseNotNullViolation
+		case 1205:
+			return ErrDatabaseDeadlock
+		}
+		return nil
+	}
+	var oraErr *network.OracleError
+	if errors.As(err, &oraErr) {
+		switch oraErr.ErrCode {
+		case 1:
+			return ErrDatabaseUniqueViolation
+		case 2291, 2292:
+			return ErrDatabaseForeignKeyViolation
+		case 1400:
+			return ErrDatabaseNotNullViolation
+		case 8177:
+			return ErrDatabaseSerializationFailure
+		case 60:
+			return ErrDatabaseDeadlock
+		}
+	}
+	return nil
+}
+
+// translateError wraps a driver error with its ErrDatabase sentinel, so callers can test it with errors.Is whatever
+// the driver. The driver error stays in the chain for errors.As.
+func translateError(err error) error {
+	if err == nil {
+		return nil
+	}
+	sentinel := driverErrorSentinel(err)
+	if sentinel == nil {
+		return err
+	}
+	return fmt.Errorf(`%w:%w`, sentinel, err)
+}
@@ -0,0 +1,71 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type DXStatement struct {
+	Query string
+	Args  []any
+}
+
+// ExecMany runs independent statements, not in a transaction, and returns their results in statement order. None of
+// the supported drivers can pipeline parameterized statements (lib/pq has no pipeline mode, MySQL multiStatements
+// does not take arguments), so they run one after another on a single connection, saving a pool round trip per
+// statement. It stops at the first error, returning the results so far and the index of the failing statement in err.
+func (d *DXDatabase) ExecMany(ctx context.Context, statements []DXStatement) (results []sql.Result, err error) {
+	if d.Connection == nil {
+		return nil, fmt.Errorf(`%w:%s`, ErrDatabaseNotConnected, d.NameId)
+	}
+	for _, statement := range statements {
+		err = d.checkReadOnly(ctx, statement.Query)
+		if err != nil {
+			return nil, err
+		}
+	}
+	session := d.session(ctx)
+	if _, isPool := session.(*sqlx.DB); isPool {
+		conn, err := d.Connection.Connx(ctx)
+		if err != nil {
+			return nil, err
+		}
+		defer func() {
+			_ = conn.Close()
+		}()
+		session = conn
+	}
+	results = make([]sql.Result, 0, len(statements))
+	for i, statement := range statements {
+		r, err := d.execOn(ctx, session, statement.Query, statement.Args)
+		if err != nil {
+			return results, fmt.Errorf("statement %d of ExecMany failed (%w)", i, err)
+		}
+		results = append(results, r)
+	}
+	return results, nil
+}
+
+// execOn is Exec on an already chosen session.
+func (d *DXDatabase) execOn(ctx context.Context, session dxDatabaseSession, query string, args []any) (r sql.Result, err error) {
+	ctx, cancel := d.StatementContext(ctx, query)
+	defer cancel()
+	ctx, err = d.runBeforeQueryHooks(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
+	boundArgs, err := d.bindArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	r, err = session.ExecContext(ctx, query, boundArgs...)
+	duration := time.Since(start)
+	d.runAfterQueryHooks(ctx, query, args, err, duration)
+	d.logStatement(duration, query, err)
+	return r, err
+}
@@ -0,0 +1,122 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+)
+
+type dxDatabaseQueryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// planText reads every result set of rows and returns one line per row, columns separated by " | ".
+func planText(rows *sql.Rows) (s string, err error) {
+	lines := []string{}
+	for {
+		columns, err := rows.Columns()
+		if err != nil {
+			return ``, err
+		}
+		for rows.Next() {
+			values := make([]sql.NullString, len(columns))
+			valuePointers := make([]any, len(columns))
+			for i := range values {
+				valuePointers[i] = &values[i]
+			}
+			err = rows.Scan(valuePointers...)
+			if err != nil {
+				return ``, err
+			}
+			parts := make([]string, len(values))
+			for i, v := range values {
+				parts[i] = v.String
+			}
+			lines = append(lines, strings.Join(parts, ` | `))
+		}
+		if !rows.NextResultSet() {
+			break
+		}
+	}
+	err = rows.Err()
+	if err != nil {
+		return ``, err
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+func queryPlanText(ctx context.Context, q dxDatabaseQueryer, query string, args ...any) (s string, err error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return ``, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	return planText(rows)
+}
+
+// Explain returns the execution plan of query without running it, using the EXPLAIN flavor of the driver.
+func (d *DXDatabase) Explain(ctx context.Context, query string, args ...any) (plan string, err error) {
+	err = d.CheckConnectionAndReconnect()
+	if err != nil {
+		return ``, err
+	}
+	driverName := d.Connection.DriverName()
+	switch driverName {
+	case "postgres", "mysql":
+		return queryPlanText(ctx, d.Connection, `EXPLAIN `+query, args...)
+	case "oracle":
+		conn, err := d.Connection.Connx(ctx)
+		if err != nil {
+			return ``, err
+		}
+		defer func() {
+			_ = conn.Close()
+		}()
+		_, err = conn.ExecContext(ctx, `EXPLAIN PLAN FOR `+query, args...)
+		if err != nil {
+			return ``, err
+		}
+		return queryPlanText(ctx, conn, `SELECT plan_table_output FROM TABLE(DBMS_XPLAN.DISPLAY())`)
+	case "sqlserver":
+		conn, err := d.Connection.Connx(ctx)
+		if err != nil {
+			return ``, err
+		}
+		defer func() {
+			_ = conn.Close()
+		}()
+		_, err = conn.ExecContext(ctx, `SET SHOWPLAN_TEXT ON`)
+		if err != nil {
+			return ``, err
+		}
+		defer func() {
+			_, _ = conn.ExecContext(context.Background(), `SET SHOWPLAN_TEXT OFF`)
+		}()
+		return queryPlanText(ctx, conn, query, args...)
+	default:
+		return ``, errors.New(`UNSUPPORTED_DATABASE_EXPLAIN:` + driverName)
+	}
+}
+
+// ExplainAnalyze runs query with PostgreSQL EXPLAIN ANALYZE and returns the plan with actual timings. The query really
+// executes, so it is run inside a read-only transaction that is always rolled back, any mutation fails instead of
+// being applied.
+func (d *DXDatabase) ExplainAnalyze(ctx context.Context, query string, args ...any) (plan string, err error) {
+	if d.DatabaseType.Driver() != "postgres" {
+		return ``, errors.New(`UNSUPPORTED_DATABASE_EXPLAIN_ANALYZE:` + d.DatabaseType.String())
+	}
+	if ClassifyStatementKind(query) != StatementKindRead {
+		return ``, errors.New(`EXPLAIN_ANALYZE_ONLY_FOR_READ_STATEMENT`)
+	}
+	err = d.ReadTx(func(dtx *DXDatabaseTx) (err error) {
+		plan, err = queryPlanText(ctx, dtx.Tx, `EXPLAIN ANALYZE `+query, args...)
+		return err
+	})
+	if err != nil {
+		return ``, err
+	}
+	return plan, nil
+}
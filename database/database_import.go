@@ -0,0 +1,136 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type DXDatabaseImportOptions struct {
+	BatchSize int
+	MaxErrors int
+}
+
+type DXDatabaseImportRowError struct {
+	RowIndex int
+	Err      error
+}
+
+type DXDatabaseImportResult struct {
+	SuccessCount int
+	RowErrors    []DXDatabaseImportRowError
+}
+
+var ErrDatabaseImportTooManyErrors = errors.New(`IMPORT_TOO_MANY_ERRORS`)
+
+// ImportRows inserts rows into tableName inside one transaction, each batch of opts.BatchSize rows (default 1) guarded
+// by a savepoint. When a batch fails it is rolled back to its savepoint and its rows are retried one by one, so only
+// the failing rows are skipped and reported in the result. Reaching opts.MaxErrors (0 means unlimited) rolls back the
+// whole import.
+func (d *DXDatabase) ImportRows(tableName string, columns []string, rows [][]any, opts DXDatabaseImportOptions) (result DXDatabaseImportResult, err error) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 1
+	}
+	result.RowErrors = []DXDatabaseImportRowError{}
+	err = d.TxWithOptions(nil, func(dtx *DXDatabaseTx) (err error) {
+		result.SuccessCount = 0
+		result.RowErrors = []DXDatabaseImportRowError{}
+		driverName := dtx.DriverName()
+		statement, err := importInsertStatement(driverName, tableName, columns)
+		if err != nil {
+			return err
+		}
+		for start := 0; start < len(rows); start += opts.BatchSize {
+			end := start + opts.BatchSize
+			if end > len(rows) {
+				end = len(rows)
+			}
+			rowErr, err := importRowsInSavepoint(dtx, driverName, statement, rows, start, end)
+			if err != nil {
+				return err
+			}
+			if rowErr == nil {
+				result.SuccessCount += end - start
+				continue
+			}
+			if end-start == 1 {
+				result.RowErrors = append(result.RowErrors, DXDatabaseImportRowError{RowIndex: start, Err: rowErr})
+			} else {
+				for i := start; i < end; i++ {
+					rowErr, err := importRowsInSavepoint(dtx, driverName, statement, rows, i, i+1)
+					if err != nil {
+						return err
+					}
+					if rowErr != nil {
+						result.RowErrors = append(result.RowErrors, DXDatabaseImportRowError{RowIndex: i, Err: rowErr})
+						continue
+					}
+					result.SuccessCount++
+				}
+			}
+			if (opts.MaxErrors > 0) && (len(result.RowErrors) >= opts.MaxErrors) {
+				return fmt.Errorf(`%w:%d`, ErrDatabaseImportTooManyErrors, len(result.RowErrors))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+func importInsertStatement(driverName string, tableName string, columns []string) (s string, err error) {
+	placeholders := make([]string, len(columns))
+	for i := range columns {
+		switch driverName {
+		case "postgres":
+			placeholders[i] = `$` + strconv.Itoa(i+1)
+		case "mysql":
+			placeholders[i] = `?`
+		case "sqlserver":
+			placeholders[i] = `@p` + strconv.Itoa(i+1)
+		case "oracle":
+			placeholders[i] = `:` + strconv.Itoa(i+1)
+		default:
+			return ``, errors.New(`UNSUPPORTED_DATABASE_SQL_INSERT:` + driverName)
+		}
+	}
+	s = `insert into ` + tableName + ` (` + strings.Join(columns, `, `) + `) values (` + strings.Join(placeholders, `, `) + `)`
+	return s, nil
+}
+
+// importRowsInSavepoint inserts rows[start:end] under a savepoint. rowErr is the insert error after rolling back to the
+// savepoint, err is a savepoint failure that leaves the transaction unusable.
+func importRowsInSavepoint(dtx *DXDatabaseTx, driverName string, statement string, rows [][]any, start int, end int) (rowErr error, err error) {
+	savepointName := `dxlib_import_` + strconv.Itoa(start)
+	createSavepoint, rollbackSavepoint, releaseSavepoint := `SAVEPOINT `+savepointName, `ROLLBACK TO SAVEPOINT `+savepointName, `RELEASE SAVEPOINT `+savepointName
+	switch driverName {
+	case "sqlserver":
+		createSavepoint, rollbackSavepoint, releaseSavepoint = `SAVE TRANSACTION `+savepointName, `ROLLBACK TRANSACTION `+savepointName, ``
+	case "oracle":
+		releaseSavepoint = ``
+	}
+	_, err = dtx.Exec(createSavepoint)
+	if err != nil {
+		return nil, err
+	}
+	for i := start; i < end; i++ {
+		_, rowErr = dtx.Exec(statement, rows[i]...)
+		if rowErr != nil {
+			_, err = dtx.Exec(rollbackSavepoint)
+			if err != nil {
+				return rowErr, err
+			}
+			return rowErr, nil
+		}
+	}
+	if releaseSavepoint != `` {
+		_, err = dtx.Exec(releaseSavepoint)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,185 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/donnyhardyanto/dxlib/log"
+)
+
+var ErrDatabaseInvalidIdentifier = errors.New(`DATABASE_INVALID_IDENTIFIER`)
+
+var maintenanceTableNameRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_$]*(\.[A-Za-z_][A-Za-z0-9_$]*)?$`)
+
+// runMaintenance runs a maintenance statement on the pool in autocommit mode, never inside a transaction, without the
+// read/write default timeout since maintenance on a large table easily outlasts it.
+func (d *DXDatabase) runMaintenance(statement string, args ...any) (err error) {
+	if d.Connection == nil {
+		return fmt.Errorf(`%w:%s`, ErrDatabaseNotConnected, d.NameId)
+	}
+	err = d.checkWritable()
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	_, err = d.Connection.ExecContext(context.Background(), statement, args...)
+	d.logStatement(time.Since(start), statement, err)
+	return err
+}
+
+// Analyze refreshes the planner statistics of tableName: ANALYZE on PostgreSQL, ANALYZE TABLE on MySQL, UPDATE
+// STATISTICS on SQL Server and DBMS_STATS.GATHER_TABLE_STATS on Oracle.
+func (d *DXDatabase) Analyze(tableName string) (err error) {
+	if !maintenanceTableNameRegexp.MatchString(tableName) {
+		return fmt.Errorf(`%w:%s`, ErrDatabaseInvalidIdentifier, tableName)
+	}
+	if d.Connection == nil {
+		return fmt.Errorf(`%w:%s`, ErrDatabaseNotConnected, d.NameId)
+	}
+	switch d.Connection.DriverName() {
+	case "postgres":
+		return d.runMaintenance(`ANALYZE ` + tableName)
+	case "mysql":
+		return d.runMaintenance(`ANALYZE TABLE ` + tableName)
+	case "sqlserver":
+		return d.runMaintenance(`UPDATE STATISTICS ` + tableName)
+	case "oracle":
+		return d.runMaintenance(`BEGIN DBMS_STATS.GATHER_TABLE_STATS(USER, :1); END;`, tableName)
+	default:
+		err = log.Log.ErrorAndCreateErrorf("Analyze is not supported for database driver %s", d.Connection.DriverName())
+		return err
+	}
+}
+
+// Vacuum reclaims the space of dead rows of tableName: VACUUM (FULL when full, which locks the table) on PostgreSQL and
+// OPTIMIZE TABLE on MySQL, where full is ignored. Other databases return an error.
+func (d *DXDatabase) Vacuum(tableName string, full bool) (err error) {
+	if !maintenanceTableNameRegexp.MatchString(tableName) {
+		return fmt.Errorf(`%w:%s`, ErrDatabaseInvalidIdentifier, tableName)
+	}
+	if d.Connection == nil {
+		return fmt.Errorf(`%w:%s`, ErrDatabaseNotConnected, d.NameId)
+	}
+	switch d.Connection.DriverName() {
+	case "postgres":
+		if full {
+			return d.runMaintenance(`VACUUM FULL ` + tableName)
+		}
+		return d.runMaintenance(`VACUUM ` + tableName)
+	case "mysql":
+		return d.runMaintenance(`OPTIMIZE TABLE ` + tableName)
+	default:
+		err = log.Log.ErrorAndCreateErrorf("Vacuum is not supported for database driver %s", d.Connection.DriverName())
+		return err
+	}
+}
+
+var ErrDatabaseTruncateNotAllowed = errors.New(`DATABASE_TRUNCATE_NOT_ALLOWED`)
+
+// Truncate empties tables, meant for test fixtures and data resets, and refuses to run unless allow_truncate is set in
+// the database configuration. PostgreSQL truncates all tables in one statement, honoring restartIdentity and cascade.
+// MySQL and SQL Server always restart identities; MySQL emulates cascade by disabling foreign key checks on the
+// connection, SQL Server does not support it. Oracle supports cascade, restartIdentity is ignored.
+func (d *DXDatabase) Truncate(tables []string, restartIdentity, cascade bool) (err error) {
+	if !d.AllowTruncate {
+		return fmt.Errorf(`%w:%s`, ErrDatabaseTruncateNotAllowed, d.NameId)
+	}
+	for _, tableName := range tables {
+		if !maintenanceTableNameRegexp.MatchString(tableName) {
+			return fmt.Errorf(`%w:%s`, ErrDatabaseInvalidIdentifier, tableName)
+		}
+	}
+	if len(tables) == 0 {
+		return nil
+	}
+	if d.Connection == nil {
+		return fmt.Errorf(`%w:%s`, ErrDatabaseNotConnected, d.NameId)
+	}
+	switch d.Connection.DriverName() {
+	case "postgres":
+		statement := `TRUNCATE ` + strings.Join(tables, `, `)
+		if restartIdentity {
+			statement = statement + ` RESTART IDENTITY`
+		}
+		if cascade {
+			statement = statement + ` CASCADE`
+		}
+		return d.runMaintenance(statement)
+	case "mysql":
+		return d.truncateMySQL(tables, cascade)
+	case "sqlserver":
+		if cascade {
+			err = log.Log.ErrorAndCreateErrorf("Truncate with cascade is not supported for database driver %s", d.Connection.DriverName())
+			return err
+		}
+		for _, tableName := range tables {
+			err = d.runMaintenance(`TRUNCATE TABLE ` + tableName)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	case "oracle":
+		for _, tableName := range tables {
+			statement := `TRUNCATE TABLE ` + tableName
+			if cascade {
+				statement = statement + ` CASCADE`
+			}
+			err = d.runMaintenance(statement)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		err = log.Log.ErrorAndCreateErrorf("Truncate is not supported for database driver %s", d.Connection.DriverName())
+		return err
+	}
+}
+
+// truncateMySQL truncates on one connection, so disabling foreign key checks for cascade applies to the TRUNCATE
+// statements and is restored before the connection goes back to the pool.
+func (d *DXDatabase) truncateMySQL(tables []string, cascade bool) (err error) {
+	err = d.checkWritable()
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	conn, err := d.Connection.Connx(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+	if cascade {
+		_, err = conn.ExecContext(ctx, `SET FOREIGN_KEY_CHECKS = 0`)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_, errRestore := conn.ExecContext(ctx, `SET FOREIGN_KEY_CHECKS = 1`)
+			if errRestore != nil {
+				log.Log.Errorf("Database %s cannot restore foreign key checks, discarding the connection (%s)", d.NameId, errRestore.Error())
+				_ = conn.Raw(func(any) error {
+					return driver.ErrBadConn
+				})
+			}
+		}()
+	}
+	for _, tableName := range tables {
+		statement := `TRUNCATE TABLE ` + tableName
+		start := time.Now()
+		_, err = conn.ExecContext(ctx, statement)
+		d.logStatement(time.Since(start), statement, err)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
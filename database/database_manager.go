@@ -1,6 +1,9 @@
 package database
 
 import (
+	"fmt"
+	"sync"
+
 	dxlibv3Configuration "github.com/donnyhardyanto/dxlib/configuration"
 	"github.com/donnyhardyanto/dxlib/database/protected/db"
 	"github.com/donnyhardyanto/dxlib/log"
@@ -9,9 +12,13 @@ import (
 
 type DXDatabaseSQLExpression = db.SQLExpression
 
+type DXDatabaseStateChangeFunc func(nameId string, connected bool)
+
 type DXDatabaseManager struct {
-	Databases map[string]*DXDatabase
-	Scripts   map[string]*DXDatabaseScript
+	Databases             map[string]*DXDatabase
+	Scripts               map[string]*DXDatabaseScript
+	stateChangeFuncs      []DXDatabaseStateChangeFunc
+	stateChangeFuncsMutex sync.Mutex
 }
 
 func (dm *DXDatabaseManager) NewDatabase(nameId string, isConnectAtStart, mustBeConnected bool) *DXDatabase {
@@ -19,6 +26,7 @@ func (dm *DXDatabaseManager) NewDatabase(nameId string, isConnectAtStart, mustBe
 		return dm.Databases[nameId]
 	}
 	d := DXDatabase{
+		Owner:            dm,
 		NameId:           nameId,
 		IsConfigured:     false,
 		IsConnectAtStart: isConnectAtStart,
@@ -30,6 +38,49 @@ func (dm *DXDatabaseManager) NewDatabase(nameId string, isConnectAtStart, mustBe
 	return &d
 }
 
+// NewDatabaseFromConfig creates and configures a database from an inline configuration map, without going through the
+// configuration manager. A nameId already in the manager is rejected with ErrDatabaseAlreadyExists, cfg would not be
+// applied to it.
+func (dm *DXDatabaseManager) NewDatabaseFromConfig(nameId string, cfg utils.JSON) (*DXDatabase, error) {
+	isConnectAtStart, ok := cfg[`is_connect_at_start`].(bool)
+	if !ok {
+		isConnectAtStart = false
+	}
+	mustConnected, ok := cfg[`must_connected`].(bool)
+	if !ok {
+		mustConnected = false
+	}
+	if dm.Databases[nameId] != nil {
+		return nil, fmt.Errorf(`%w:%s`, ErrDatabaseAlreadyExists, nameId)
+	}
+	d := dm.NewDatabase(nameId, isConnectAtStart, mustConnected)
+	log.Log.Infof("Configuring to Database %s... start", d.NameId)
+	err := d.ApplyConfigurationData(cfg)
+	if err != nil {
+		delete(dm.Databases, nameId)
+		return nil, err
+	}
+	log.Log.Infof("Configuring to Database %s... done", d.NameId)
+	return d, nil
+}
+
+// OnStateChange registers callback to be called on every connect, disconnect and reconnect transition of any database,
+// including the first connect at start.
+func (dm *DXDatabaseManager) OnStateChange(callback DXDatabaseStateChangeFunc) {
+	dm.stateChangeFuncsMutex.Lock()
+	defer dm.stateChangeFuncsMutex.Unlock()
+	dm.stateChangeFuncs = append(dm.stateChangeFuncs, callback)
+}
+
+func (dm *DXDatabaseManager) notifyStateChange(nameId string, connected bool) {
+	dm.stateChangeFuncsMutex.Lock()
+	callbacks := append([]DXDatabaseStateChangeFunc{}, dm.stateChangeFuncs...)
+	dm.stateChangeFuncsMutex.Unlock()
+	for _, callback := range callbacks {
+		callback(nameId, connected)
+	}
+}
+
 func (dm *DXDatabaseManager) LoadFromConfiguration(configurationNameId string) (err error) {
 	configuration := dxlibv3Configuration.Manager.Configurations[configurationNameId]
 	isConnectAtStart := false
@@ -63,6 +114,10 @@ func (dm *DXDatabaseManager) ConnectAllAtStart( /*configurationNameId string*/ )
 		for _, v := range dm.Databases {
 			err := v.ApplyFromConfiguration( /* configurationNameId */ )
 			if err != nil {
+				if v.MustConnected {
+					log.Log.Fatalf("Cannot configure to database %s to connect (%s)", v.NameId, err.Error())
+					return err
+				}
 				err = log.Log.ErrorAndCreateErrorf("Cannot configure to database %s to connect", v.NameId)
 				return err
 			}
@@ -82,6 +137,10 @@ func (dm *DXDatabaseManager) ConnectAll(configurationNameId string) (err error)
 	for _, v := range dm.Databases {
 		err := v.ApplyFromConfiguration( /*configurationNameId*/ )
 		if err != nil {
+			if v.MustConnected {
+				log.Log.Fatalf("Cannot configure to database %s to connect (%s)", v.NameId, err.Error())
+				return err
+			}
 			err = log.Log.ErrorAndCreateErrorf("Cannot configure to database %s to connect", v.NameId)
 			return err
 		}
@@ -0,0 +1,110 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/donnyhardyanto/dxlib/database/database_type"
+	"github.com/donnyhardyanto/dxlib/log"
+)
+
+type DXDatabaseMultiTxItem struct {
+	Database *DXDatabase
+	Callback DXDatabaseTxCallback
+}
+
+type dxDatabaseMultiTxEntry struct {
+	item                   DXDatabaseMultiTxItem
+	dtx                    *DXDatabaseTx
+	preparedTransactionGId string
+	isPreparedTransaction  bool
+}
+
+// MultiTx runs every item callback in its own transaction and commits them in sequence. This is best-effort, not a
+// real two phase commit: all callbacks run first and any callback error rolls back every transaction, but once commits
+// start a failing commit cannot undo the ones before it. committedNameIds always reports which databases did commit, so
+// the caller can compensate.
+//
+// With usePreparedTransaction, PostgreSQL databases run PREPARE TRANSACTION after their callback and only COMMIT
+// PREPARED in the commit phase, which narrows the failure window to the COMMIT PREPARED itself. This needs
+// max_prepared_transactions > 0 on the server. Other database types ignore the flag.
+func (dm *DXDatabaseManager) MultiTx(log *log.DXLog, isolationLevel sql.IsolationLevel, usePreparedTransaction bool, items ...DXDatabaseMultiTxItem) (committedNameIds []string, err error) {
+	committedNameIds = []string{}
+	entries := []*dxDatabaseMultiTxEntry{}
+
+	rollbackAll := func() {
+		for _, e := range entries {
+			if e.isPreparedTransaction {
+				_, errTx := e.item.Database.Connection.Exec(`ROLLBACK PREPARED '` + e.preparedTransactionGId + `'`)
+				if errTx != nil {
+					log.Errorf(`MULTI_TX_ERROR_IN_ROLLBACK_PREPARED:%s:%s (%v)`, e.item.Database.NameId, e.preparedTransactionGId, errTx.Error())
+				}
+				continue
+			}
+			errTx := e.dtx.rollback()
+			if (errTx != nil) && (!errors.Is(errTx, sql.ErrTxDone)) {
+				log.Errorf(`MULTI_TX_ERROR_IN_ROLLBACK:%s (%v)`, e.item.Database.NameId, errTx.Error())
+			}
+		}
+	}
+
+	for i, item := range items {
+		err = item.Database.CheckConnectionAndReconnect()
+		if err != nil {
+			rollbackAll()
+			return committedNameIds, err
+		}
+		dtx, err := item.Database.begin(log.Context, &sql.TxOptions{
+			Isolation: isolationLevel,
+			ReadOnly:  false,
+		}, log)
+		if err != nil {
+			log.Errorf(`MULTI_TX_ERROR_IN_BEGIN:%s (%v)`, item.Database.NameId, err.Error())
+			rollbackAll()
+			return committedNameIds, err
+		}
+		e := &dxDatabaseMultiTxEntry{
+			item: item,
+			dtx:  dtx,
+		}
+		entries = append(entries, e)
+		err = item.Callback(e.dtx)
+		if err != nil {
+			log.Errorf(`MULTI_TX_ERROR_IN_CALLBACK:%s (%v)`, item.Database.NameId, err.Error())
+			rollbackAll()
+			return committedNameIds, err
+		}
+		if usePreparedTransaction && (item.Database.DatabaseType == database_type.PostgreSQL) {
+			gId := fmt.Sprintf(`dxlib_%s_%d_%d`, strings.ReplaceAll(item.Database.NameId, `'`, `''`), time.Now().UnixNano(), i)
+			_, err = dtx.Tx.Exec(`PREPARE TRANSACTION '` + gId + `'`)
+			if err != nil {
+				log.Errorf(`MULTI_TX_ERROR_IN_PREPARE_TRANSACTION:%s (%v)`, item.Database.NameId, err.Error())
+				rollbackAll()
+				return committedNameIds, err
+			}
+			// The session is no longer in a transaction after PREPARE TRANSACTION, this only releases the connection.
+			_ = dtx.rollback()
+			e.preparedTransactionGId = gId
+			e.isPreparedTransaction = true
+		}
+	}
+
+	for i, e := range entries {
+		if e.isPreparedTransaction {
+			_, err = e.item.Database.Connection.Exec(`COMMIT PREPARED '` + e.preparedTransactionGId + `'`)
+		} else {
+			err = e.dtx.commit()
+		}
+		if err != nil {
+			log.Errorf(`MULTI_TX_ERROR_IN_COMMIT:%s (%v), already committed: %v`, e.item.Database.NameId, err.Error(), committedNameIds)
+			entries = entries[i:]
+			rollbackAll()
+			return committedNameIds, fmt.Errorf(`MULTI_TX_PARTIAL_COMMIT:%s:%w`, e.item.Database.NameId, err)
+		}
+		committedNameIds = append(committedNameIds, e.item.Database.NameId)
+	}
+	return committedNameIds, nil
+}
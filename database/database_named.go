@@ -0,0 +1,159 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	libPq "github.com/lib/pq"
+
+	"github.com/donnyhardyanto/dxlib/database/protected/db"
+	"github.com/donnyhardyanto/dxlib/utils"
+)
+
+var ErrDatabaseNamedParameterMissing = errors.New(`DATABASE_NAMED_PARAMETER_MISSING`)
+
+func isNamedParameterChar(c byte) bool {
+	return (c == '_') || ((c >= 'a') && (c <= 'z')) || ((c >= 'A') && (c <= 'Z')) || ((c >= '0') && (c <= '9'))
+}
+
+// expandNamedParameters replaces every :name in query with the positional placeholder of driverName and returns the
+// matching arguments in order. Quoted strings, quoted identifiers and PostgreSQL :: casts are left untouched.
+func expandNamedParameters(driverName string, query string, params utils.JSON) (s string, args []any, err error) {
+	var sb strings.Builder
+	n := len(query)
+	for i := 0; i < n; i++ {
+		c := query[i]
+		switch {
+		case (c == '\'') || (c == '"') || (c == '`'):
+			j := strings.IndexByte(query[i+1:], c)
+			if j < 0 {
+				sb.WriteString(query[i:])
+				i = n
+				continue
+			}
+			sb.WriteString(query[i : i+j+2])
+			i += j + 1
+		case (c == ':') && (i+1 < n) && (query[i+1] == ':'):
+			sb.WriteString(`::`)
+			i++
+		case (c == ':') && (i+1 < n) && isNamedParameterChar(query[i+1]):
+			j := i + 1
+			for (j < n) && isNamedParameterChar(query[j]) {
+				j++
+			}
+			name := query[i+1 : j]
+			v, ok := params[name]
+			if !ok {
+				return "", nil, fmt.Errorf(`%w:%s`, ErrDatabaseNamedParameterMissing, name)
+			}
+			switch condition := v.(type) {
+			case db.SQLInCondition:
+				var conditionSQL string
+				conditionSQL, args = expandInCondition(driverName, condition, args)
+				sb.WriteString(conditionSQL)
+			case db.SQLJSONBCondition:
+				var conditionSQL string
+				conditionSQL, args, err = expandJSONBCondition(driverName, condition, args)
+				if err != nil {
+					return "", nil, err
+				}
+				sb.WriteString(conditionSQL)
+			default:
+				args = append(args, v)
+				sb.WriteString(bulkPlaceholder(driverName, len(args)))
+			}
+			i = j - 1
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String(), args, nil
+}
+
+// expandInCondition renders condition for driverName, appending its values to args.
+func expandInCondition(driverName string, condition db.SQLInCondition, args []any) (s string, newArgs []any) {
+	if len(condition.Values) == 0 {
+		return `1 = 0`, args
+	}
+	if driverName == "postgres" {
+		args = append(args, libPq.Array(condition.Values))
+		return condition.Column + ` = ANY(` + bulkPlaceholder(driverName, len(args)) + `)`, args
+	}
+	chunkSize := len(condition.Values)
+	if driverName == "oracle" {
+		chunkSize = 1000
+	}
+	lists := []string{}
+	for start := 0; start < len(condition.Values); start += chunkSize {
+		end := min(start+chunkSize, len(condition.Values))
+		placeholders := make([]string, 0, end-start)
+		for _, v := range condition.Values[start:end] {
+			args = append(args, v)
+			placeholders = append(placeholders, bulkPlaceholder(driverName, len(args)))
+		}
+		lists = append(lists, condition.Column+` IN (`+strings.Join(placeholders, `, `)+`)`)
+	}
+	if len(lists) == 1 {
+		return lists[0], args
+	}
+	return `(` + strings.Join(lists, ` OR `) + `)`, args
+}
+
+// expandJSONBCondition renders condition for PostgreSQL, appending its value to args.
+func expandJSONBCondition(driverName string, condition db.SQLJSONBCondition, args []any) (s string, newArgs []any, err error) {
+	if driverName != "postgres" {
+		return "", nil, errors.New(`UNSUPPORTED_DATABASE_JSONB:` + driverName)
+	}
+	switch condition.Operator {
+	case db.SQLJSONBOperatorContains:
+		valueAsBytes, err := json.Marshal(condition.Value)
+		if err != nil {
+			return "", nil, err
+		}
+		args = append(args, string(valueAsBytes))
+		return condition.Column + ` @> ` + bulkPlaceholder(driverName, len(args)) + `::jsonb`, args, nil
+	case db.SQLJSONBOperatorHasKey:
+		args = append(args, condition.Value)
+		return condition.Column + ` ? ` + bulkPlaceholder(driverName, len(args)), args, nil
+	default:
+		return "", nil, errors.New(`UNSUPPORTED_JSONB_OPERATOR:` + condition.Operator)
+	}
+}
+
+// NamedExec runs statement with its :name placeholders taken from params, through Exec.
+func (d *DXDatabase) NamedExec(ctx context.Context, statement string, params utils.JSON) (r sql.Result, err error) {
+	if d.Connection == nil {
+		return nil, fmt.Errorf(`%w:%s`, ErrDatabaseNotConnected, d.NameId)
+	}
+	s, args, err := expandNamedParameters(d.Connection.DriverName(), statement, params)
+	if err != nil {
+		return nil, err
+	}
+	return d.Exec(ctx, s, args...)
+}
+
+// NamedQuery runs query with its :name placeholders taken from params, through Query. The caller must close the rows.
+func (d *DXDatabase) NamedQuery(ctx context.Context, query string, params utils.JSON) (rows *sql.Rows, err error) {
+	if d.Connection == nil {
+		return nil, fmt.Errorf(`%w:%s`, ErrDatabaseNotConnected, d.NameId)
+	}
+	s, args, err := expandNamedParameters(d.Connection.DriverName(), query, params)
+	if err != nil {
+		return nil, err
+	}
+	return d.Query(ctx, s, args...)
+}
+
+// QueryExpression renders se with its WITH clause and params and runs it through NamedQuery. The caller must close the
+// rows.
+func (d *DXDatabase) QueryExpression(ctx context.Context, se DXDatabaseSQLExpression) (rows *sql.Rows, err error) {
+	query, params, err := se.SQL()
+	if err != nil {
+		return nil, err
+	}
+	return d.NamedQuery(ctx, query, params)
+}
@@ -0,0 +1,62 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Now returns the current time of the database server, to reconcile the application clock with it. MySQL has no
+// zoned timestamp, UTC_TIMESTAMP is read and taken as UTC whatever the loc of the DSN.
+func (d *DXDatabase) Now(ctx context.Context) (now time.Time, err error) {
+	if d.Connection == nil {
+		return time.Time{}, fmt.Errorf(`%w:%s`, ErrDatabaseNotConnected, d.NameId)
+	}
+	driverName := d.Connection.DriverName()
+	query := ``
+	switch driverName {
+	case "postgres":
+		query = `SELECT now()`
+	case "mysql":
+		query = `SELECT UTC_TIMESTAMP(6)`
+	case "sqlserver":
+		query = `SELECT SYSDATETIMEOFFSET()`
+	case "oracle":
+		query = `SELECT SYSTIMESTAMP FROM DUAL`
+	default:
+		return time.Time{}, errors.New(`UNSUPPORTED_DATABASE_NOW:` + driverName)
+	}
+	rows, err := d.Query(ctx, query)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	if !rows.Next() {
+		err = rows.Err()
+		if err == nil {
+			err = fmt.Errorf("database %s returned no current time", d.NameId)
+		}
+		return time.Time{}, err
+	}
+	var v any
+	err = rows.Scan(&v)
+	if err != nil {
+		return time.Time{}, err
+	}
+	switch t := v.(type) {
+	case time.Time:
+		if driverName == "mysql" {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+		}
+		return t, nil
+	case []byte:
+		return time.ParseInLocation(`2006-01-02 15:04:05.999999`, string(t), time.UTC)
+	case string:
+		return time.ParseInLocation(`2006-01-02 15:04:05.999999`, t, time.UTC)
+	default:
+		return time.Time{}, fmt.Errorf("database %s returned current time as unexpected %T", d.NameId, v)
+	}
+}
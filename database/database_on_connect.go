@@ -0,0 +1,107 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// DXDatabaseOnConnectFunc runs once on every new pooled connection, before it is handed out. Returning an error fails
+// the connection acquisition.
+type DXDatabaseOnConnectFunc func(ctx context.Context, conn driver.Conn) (err error)
+
+// SetOnConnect sets the Go session setup callback, it runs after the on_connect statements. It takes effect on the next
+// Connect.
+func (d *DXDatabase) SetOnConnect(callback DXDatabaseOnConnectFunc) {
+	d.OnConnect = callback
+}
+
+type dxDatabaseOnConnectConnector struct {
+	d         *DXDatabase
+	connector driver.Connector
+}
+
+type dxDatabaseDSNConnector struct {
+	dsn    string
+	driver driver.Driver
+}
+
+func (c dxDatabaseDSNConnector) Connect(_ context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.dsn)
+}
+
+func (c dxDatabaseDSNConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+func (c dxDatabaseOnConnectConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	err = c.d.initConnection(ctx, conn)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+func (c dxDatabaseOnConnectConnector) Driver() driver.Driver {
+	return c.connector.Driver()
+}
+
+// openWithOnConnect opens the pool through a connector that runs the session setup on every new connection.
+func (d *DXDatabase) openWithOnConnect(driverName string, dsn string) (*sqlx.DB, error) {
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+	drv := db.Driver()
+	_ = db.Close()
+	var connector driver.Connector = dxDatabaseDSNConnector{dsn: dsn, driver: drv}
+	if driverContext, ok := drv.(driver.DriverContext); ok {
+		connector, err = driverContext.OpenConnector(dsn)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return sqlx.NewDb(sql.OpenDB(dxDatabaseOnConnectConnector{d: d, connector: connector}), driverName), nil
+}
+
+func (d *DXDatabase) initConnection(ctx context.Context, conn driver.Conn) (err error) {
+	for _, statement := range d.OnConnectStatements {
+		err = execOnDriverConn(ctx, conn, statement)
+		if err != nil {
+			return fmt.Errorf("database %s on_connect statement failed (%w): %s", d.NameId, err, statement)
+		}
+	}
+	if d.OnConnect != nil {
+		err = d.OnConnect(ctx, conn)
+		if err != nil {
+			return fmt.Errorf("database %s on_connect callback failed (%w)", d.NameId, err)
+		}
+	}
+	return nil
+}
+
+func execOnDriverConn(ctx context.Context, conn driver.Conn, statement string) (err error) {
+	if execer, ok := conn.(driver.ExecerContext); ok {
+		_, err = execer.ExecContext(ctx, statement, nil)
+		if err != driver.ErrSkip {
+			return err
+		}
+	}
+	stmt, err := conn.Prepare(statement)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = stmt.Close()
+	}()
+	_, err = stmt.Exec(nil)
+	return err
+}
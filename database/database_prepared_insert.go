@@ -0,0 +1,107 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/donnyhardyanto/dxlib/log"
+)
+
+const DXPreparedInsertDefaultBatchSize = 500
+
+// DXPreparedInsert inserts rows with one statement prepared once. Added rows are buffered and flushed in batches of
+// BatchSize rows, each batch in its own transaction. It is not safe for concurrent use.
+type DXPreparedInsert struct {
+	Database      *DXDatabase
+	TableName     string
+	Columns       []string
+	BatchSize     int
+	ctx           context.Context
+	stmt          *sqlx.Stmt
+	buffer        [][]any
+	insertedCount int64
+}
+
+// PreparedInsert prepares the insert of columns into tableName and returns the handle to feed rows to. Close must be
+// called to flush the last batch and release the statement.
+func (d *DXDatabase) PreparedInsert(ctx context.Context, tableName string, columns []string) (pi *DXPreparedInsert, err error) {
+	if d.Connection == nil {
+		return nil, fmt.Errorf(`%w:%s`, ErrDatabaseNotConnected, d.NameId)
+	}
+	err = d.checkWritable()
+	if err != nil {
+		return nil, err
+	}
+	statement, err := importInsertStatement(d.Connection.DriverName(), tableName, columns)
+	if err != nil {
+		return nil, err
+	}
+	stmt, err := d.Connection.PreparexContext(ctx, statement)
+	if err != nil {
+		log.Log.Errorf("Database %s cannot prepare insert (%s) %s", d.NameId, err.Error(), statement)
+		return nil, err
+	}
+	return &DXPreparedInsert{
+		Database:  d,
+		TableName: tableName,
+		Columns:   columns,
+		BatchSize: DXPreparedInsertDefaultBatchSize,
+		ctx:       ctx,
+		stmt:      stmt,
+		buffer:    [][]any{},
+	}, nil
+}
+
+// Add buffers one row, values in Columns order, and flushes the buffer once it holds BatchSize rows.
+func (pi *DXPreparedInsert) Add(values ...any) (err error) {
+	if len(values) != len(pi.Columns) {
+		return fmt.Errorf("prepared insert into %s expects %d values, got %d", pi.TableName, len(pi.Columns), len(values))
+	}
+	pi.buffer = append(pi.buffer, values)
+	if len(pi.buffer) >= pi.BatchSize {
+		return pi.Flush()
+	}
+	return nil
+}
+
+// Flush inserts the buffered rows in one transaction. On error the whole batch is rolled back and kept out of the
+// inserted count.
+func (pi *DXPreparedInsert) Flush() (err error) {
+	if len(pi.buffer) == 0 {
+		return nil
+	}
+	dtx, err := pi.Database.begin(pi.ctx, nil, &log.Log)
+	if err != nil {
+		return err
+	}
+	txStmt := dtx.StmtxContext(pi.ctx, pi.stmt)
+	for _, values := range pi.buffer {
+		_, err = txStmt.ExecContext(pi.ctx, values...)
+		if err != nil {
+			log.Log.Errorf("Database %s prepared insert into %s failed, batch rolled back (%s)", pi.Database.NameId, pi.TableName, err.Error())
+			_ = dtx.rollback()
+			pi.buffer = pi.buffer[:0]
+			return err
+		}
+	}
+	err = dtx.Commit()
+	if err != nil {
+		pi.buffer = pi.buffer[:0]
+		return err
+	}
+	pi.insertedCount += int64(len(pi.buffer))
+	pi.buffer = pi.buffer[:0]
+	return nil
+}
+
+// Close flushes the remaining rows, releases the prepared statement and returns the number of rows inserted.
+func (pi *DXPreparedInsert) Close() (insertedCount int64, err error) {
+	err = pi.Flush()
+	closeErr := pi.stmt.Close()
+	if err == nil {
+		err = closeErr
+	}
+	return pi.insertedCount, err
+}
@@ -0,0 +1,108 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/donnyhardyanto/dxlib/log"
+	"github.com/donnyhardyanto/dxlib/utils"
+)
+
+const DatabaseQueryCacheKeyPrefix = "query_cache:"
+
+// DXDatabaseQueryCache is the store QueryCached keeps results in. GetBytes returns nil, nil on a miss. A *redis.DXRedis
+// satisfies it.
+type DXDatabaseQueryCache interface {
+	GetBytes(key string) ([]byte, error)
+	SetBytes(key string, b []byte, expirationDuration time.Duration) error
+	Delete(key string) error
+}
+
+// QueryCacheKey derives the key QueryCached stores the result of query with args under. The args are serialized as
+// JSON, which writes map keys sorted, so the key is the same across runs and processes.
+func (d *DXDatabase) QueryCacheKey(query string, args ...any) (key string, err error) {
+	argsAsBytes, err := json.Marshal(args)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	h.Write([]byte(d.NameId))
+	h.Write([]byte{0})
+	h.Write([]byte(query))
+	h.Write([]byte{0})
+	h.Write(argsAsBytes)
+	return DatabaseQueryCacheKeyPrefix + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// QueryCached returns the rows of query from cache when present, otherwise runs it and caches the rows for ttl. Errors
+// of the cache are logged and the query result is returned anyway. Rows are returned as decoded from their cached JSON
+// on a miss too, so both give the same types: numbers are json.Number, times RFC 3339 strings and binary values
+// strings.
+func (d *DXDatabase) QueryCached(ctx context.Context, cache DXDatabaseQueryCache, ttl time.Duration, query string, args ...any) (rows []utils.JSON, err error) {
+	key, err := d.QueryCacheKey(query, args...)
+	if err != nil {
+		log.Log.Errorf("Database %s cannot derive query cache key (%s)", d.NameId, err.Error())
+		return nil, err
+	}
+	cached, err := cache.GetBytes(key)
+	if err != nil {
+		log.Log.Warnf("Database %s cannot read query cache, treated as miss (%s) %s", d.NameId, err.Error(), key)
+	} else if cached != nil {
+		rows, err = decodeQueryCacheRows(cached)
+		if err == nil {
+			return rows, nil
+		}
+		log.Log.Debugf("Database %s cannot decode cached query, treated as miss %s", d.NameId, key)
+	}
+	rows = []utils.JSON{}
+	err = d.StreamQuery(ctx, query, args, func(row utils.JSON) error {
+		for k, v := range row {
+			if b, ok := v.([]byte); ok {
+				row[k] = string(b)
+			}
+		}
+		rows = append(rows, row)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	rowsAsBytes, err := json.Marshal(rows)
+	if err != nil {
+		log.Log.Errorf("Database %s cannot marshal query result for cache (%s) %s", d.NameId, err.Error(), key)
+		return rows, nil
+	}
+	err = cache.SetBytes(key, rowsAsBytes, ttl)
+	if err != nil {
+		log.Log.Warnf("Database %s cannot write query cache (%s) %s", d.NameId, err.Error(), key)
+	}
+	decodedRows, err := decodeQueryCacheRows(rowsAsBytes)
+	if err != nil {
+		return rows, nil
+	}
+	return decodedRows, nil
+}
+
+func decodeQueryCacheRows(b []byte) (rows []utils.JSON, err error) {
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.UseNumber()
+	err = decoder.Decode(&rows)
+	if err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// InvalidateQueryCache deletes the result QueryCached stored for query with args.
+func (d *DXDatabase) InvalidateQueryCache(cache DXDatabaseQueryCache, query string, args ...any) (err error) {
+	key, err := d.QueryCacheKey(query, args...)
+	if err != nil {
+		log.Log.Errorf("Database %s cannot derive query cache key (%s)", d.NameId, err.Error())
+		return err
+	}
+	return cache.Delete(key)
+}
@@ -0,0 +1,35 @@
+package database
+
+import (
+	"context"
+	"time"
+)
+
+// DXDatabaseQueryHook is called around every statement run through Exec, Query and StreamQuery. Before may replace the
+// context passed to the statement and to After, or abort the statement by returning an error.
+type DXDatabaseQueryHook interface {
+	Before(ctx context.Context, query string, args []any) (context.Context, error)
+	After(ctx context.Context, query string, args []any, err error, duration time.Duration)
+}
+
+// RegisterQueryHook appends hook, hooks are called in registration order.
+func (d *DXDatabase) RegisterQueryHook(hook DXDatabaseQueryHook) {
+	d.QueryHooks = append(d.QueryHooks, hook)
+}
+
+func (d *DXDatabase) runBeforeQueryHooks(ctx context.Context, query string, args []any) (context.Context, error) {
+	for _, hook := range d.QueryHooks {
+		var err error
+		ctx, err = hook.Before(ctx, query, args)
+		if err != nil {
+			return ctx, err
+		}
+	}
+	return ctx, nil
+}
+
+func (d *DXDatabase) runAfterQueryHooks(ctx context.Context, query string, args []any, err error, duration time.Duration) {
+	for _, hook := range d.QueryHooks {
+		hook.After(ctx, query, args, err, duration)
+	}
+}
@@ -0,0 +1,119 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// queryx runs query through the session of ctx with the read only guard, query hooks and statement logging, and hands
+// the rows with the scan functions of their registered types to read before closing them.
+func (d *DXDatabase) queryx(ctx context.Context, query string, args []any, read func(rows *sqlx.Rows, scanFuncs map[string]DXDatabaseTypeScanFunc) error) (err error) {
+	if d.Connection == nil {
+		return fmt.Errorf(`%w:%s`, ErrDatabaseNotConnected, d.NameId)
+	}
+	err = d.checkReadOnly(ctx, query)
+	if err != nil {
+		return err
+	}
+	ctx, err = d.runBeforeQueryHooks(ctx, query, args)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := d.StatementContext(ctx, query)
+	defer cancel()
+	start := time.Now()
+	defer func() {
+		duration := time.Since(start)
+		d.runAfterQueryHooks(ctx, query, args, err, duration)
+		d.logStatement(duration, query, err)
+	}()
+	session, release, err := d.acquireSession(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	boundArgs, err := d.bindArgs(args)
+	if err != nil {
+		return err
+	}
+	rows, err := session.QueryxContext(ctx, query, boundArgs...)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	scanFuncs, err := d.rowScanFuncs(rows)
+	if err != nil {
+		return err
+	}
+	err = read(rows, scanFuncs)
+	if err != nil {
+		return err
+	}
+	return rows.Err()
+}
+
+// QueryStruct runs query and scans every row into a T with sqlx, matching columns to the db tags of T. A column without
+// matching field is an error naming the column; NULL columns need a pointer or sql.Null* field. Columns of a type
+// registered with RegisterType are converted by its scan function and assigned to their field.
+func QueryStruct[T any](ctx context.Context, d *DXDatabase, query string, args ...any) (result []T, err error) {
+	result = []T{}
+	err = d.queryx(ctx, query, args, func(rows *sqlx.Rows, scanFuncs map[string]DXDatabaseTypeScanFunc) error {
+		for rows.Next() {
+			var row T
+			err := structScan(rows, &row, scanFuncs)
+			if err != nil {
+				return fmt.Errorf("cannot scan row into %T (%w)", row, err)
+			}
+			result = append(result, row)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// QueryFirst scans the first row of query into a T like QueryStruct, found is false when there is no row. Extra rows
+// are discarded, the rows are closed right after the first one is read.
+func QueryFirst[T any](ctx context.Context, d *DXDatabase, query string, args ...any) (result T, found bool, err error) {
+	err = d.queryx(ctx, query, args, func(rows *sqlx.Rows, scanFuncs map[string]DXDatabaseTypeScanFunc) error {
+		if !rows.Next() {
+			return nil
+		}
+		err := structScan(rows, &result, scanFuncs)
+		if err != nil {
+			return fmt.Errorf("cannot scan row into %T (%w)", result, err)
+		}
+		found = true
+		return nil
+	})
+	if err != nil {
+		var zero T
+		return zero, false, err
+	}
+	return result, found, nil
+}
+
+// RowsToMapByKey indexes rows by keyFn, a later row with the same key replaces an earlier one.
+func RowsToMapByKey[T any](rows []T, keyFn func(T) string) map[string]T {
+	m := make(map[string]T, len(rows))
+	for _, row := range rows {
+		m[keyFn(row)] = row
+	}
+	return m
+}
+
+// QueryMapByKey runs query like QueryStruct and returns the rows indexed by keyFn.
+func QueryMapByKey[T any](ctx context.Context, d *DXDatabase, keyFn func(T) string, query string, args ...any) (map[string]T, error) {
+	rows, err := QueryStruct[T](ctx, d, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return RowsToMapByKey(rows, keyFn), nil
+}
@@ -0,0 +1,97 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	databaseProtectedUtils "github.com/donnyhardyanto/dxlib/database/protected/utils"
+	"github.com/donnyhardyanto/dxlib/utils"
+)
+
+// DXQueryTiming splits the duration of one query: a high Acquire means the pool is exhausted, a high Execute a slow
+// query and a high Scan a large result.
+type DXQueryTiming struct {
+	Acquire time.Duration
+	Execute time.Duration
+	Scan    time.Duration
+}
+
+func (t DXQueryTiming) Total() time.Duration {
+	return t.Acquire + t.Execute + t.Scan
+}
+
+// QueryTimed runs query like Query, reads every row and returns them with the time spent acquiring a connection,
+// executing and scanning. A connection bound by WithTenant is already acquired, its Acquire is 0.
+func (d *DXDatabase) QueryTimed(ctx context.Context, query string, args ...any) (rows []utils.JSON, timing DXQueryTiming, err error) {
+	if d.Connection == nil {
+		return nil, timing, fmt.Errorf(`%w:%s`, ErrDatabaseNotConnected, d.NameId)
+	}
+	err = d.checkReadOnly(ctx, query)
+	if err != nil {
+		return nil, timing, err
+	}
+	ctx, err = d.runBeforeQueryHooks(ctx, query, args)
+	if err != nil {
+		return nil, timing, err
+	}
+	ctx, cancel := d.StatementContext(ctx, query)
+	defer cancel()
+	defer func() {
+		d.runAfterQueryHooks(ctx, query, args, err, timing.Total())
+		d.logStatement(timing.Total(), query, err)
+	}()
+	session := d.session(ctx)
+	if _, isPool := session.(*sqlx.DB); isPool {
+		start := time.Now()
+		conn, err := d.Connection.Connx(ctx)
+		timing.Acquire = time.Since(start)
+		d.traceAcquisition(timing.Acquire)
+		if err != nil {
+			return nil, timing, err
+		}
+		defer func() {
+			_ = conn.Close()
+		}()
+		session = conn
+	}
+	boundArgs, err := d.bindArgs(args)
+	if err != nil {
+		return nil, timing, err
+	}
+	start := time.Now()
+	r, err := session.QueryxContext(ctx, query, boundArgs...)
+	timing.Execute = time.Since(start)
+	if err != nil {
+		return nil, timing, err
+	}
+	defer func() {
+		_ = r.Close()
+	}()
+	start = time.Now()
+	scanFuncs, err := d.rowScanFuncs(r)
+	if err != nil {
+		return nil, timing, err
+	}
+	driverName := d.Connection.DriverName()
+	for r.Next() {
+		rowJSON := make(utils.JSON)
+		err = r.MapScan(rowJSON)
+		if err == nil {
+			err = applyScanFuncs(rowJSON, scanFuncs)
+		}
+		if err != nil {
+			timing.Scan = time.Since(start)
+			return nil, timing, err
+		}
+		rows = append(rows, databaseProtectedUtils.DeformatKeys(rowJSON, driverName))
+	}
+	err = r.Err()
+	timing.Scan = time.Since(start)
+	if err != nil {
+		return nil, timing, err
+	}
+	return rows, timing, nil
+}
@@ -0,0 +1,44 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+var ErrReadOnlyInstance = errors.New(`DATABASE_READ_ONLY_INSTANCE`)
+
+// checkReadOnly refuses statements classified as writes on a read_only instance, see ClassifyStatementKind. A write the
+// classifier sees as a read, like a function with side effects, must be marked with WithStatementKind to be caught.
+func (d *DXDatabase) checkReadOnly(ctx context.Context, statement string) (err error) {
+	if !d.ReadOnly {
+		return nil
+	}
+	if statementKind(ctx, statement) == StatementKindRead {
+		return nil
+	}
+	return d.checkWritable()
+}
+
+// checkWritable refuses any write on a read_only instance, for helpers that always write.
+func (d *DXDatabase) checkWritable() (err error) {
+	if d.ReadOnly {
+		return fmt.Errorf(`%w:%s`, ErrReadOnlyInstance, d.NameId)
+	}
+	return nil
+}
+
+// readOnlyTxOptions forces read-only transactions on a read_only instance, so the database itself rejects writes made
+// through the transaction. Drivers without read-only transactions then fail at begin.
+func (d *DXDatabase) readOnlyTxOptions(txOptions *sql.TxOptions) *sql.TxOptions {
+	if !d.ReadOnly {
+		return txOptions
+	}
+	effectiveTxOptions := sql.TxOptions{}
+	if txOptions != nil {
+		effectiveTxOptions = *txOptions
+	}
+	effectiveTxOptions.ReadOnly = true
+	return &effectiveTxOptions
+}
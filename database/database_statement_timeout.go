@@ -0,0 +1,74 @@
+package database
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"time"
+)
+
+type DXDatabaseStatementKind int
+
+const (
+	StatementKindAuto DXDatabaseStatementKind = iota
+	StatementKindRead
+	StatementKindWrite
+)
+
+type statementKindContextKey struct{}
+
+// WithStatementKind forces the statement kind used to pick the default timeout and by the read_only guard, for
+// statements the classification gets wrong.
+func WithStatementKind(ctx context.Context, kind DXDatabaseStatementKind) context.Context {
+	return context.WithValue(ctx, statementKindContextKey{}, kind)
+}
+
+var statementQuotedRegexp = regexp.MustCompile(`'(?:[^']|'')*'|"[^"]*"`)
+var statementWriteKeywordRegexp = regexp.MustCompile(`(?i)\b(insert|update|delete|merge)\b`)
+
+// ClassifyStatementKind classifies statement by its leading keyword. A WITH statement is a write when it contains
+// INSERT, UPDATE, DELETE or MERGE outside quotes, like a data-modifying CTE. A plain SELECT ... FOR UPDATE is a read.
+func ClassifyStatementKind(statement string) DXDatabaseStatementKind {
+	s := strings.TrimLeft(statement, " \t\r\n(")
+	i := strings.IndexAny(s, " \t\r\n(")
+	if i >= 0 {
+		s = s[:i]
+	}
+	switch strings.ToLower(s) {
+	case "with":
+		if statementWriteKeywordRegexp.MatchString(statementQuotedRegexp.ReplaceAllString(statement, ``)) {
+			return StatementKindWrite
+		}
+		return StatementKindRead
+	case "select", "show", "explain", "describe", "values", "table":
+		return StatementKindRead
+	default:
+		return StatementKindWrite
+	}
+}
+
+// statementKind returns the kind forced by WithStatementKind, or the one classified from statement.
+func statementKind(ctx context.Context, statement string) DXDatabaseStatementKind {
+	kind, ok := ctx.Value(statementKindContextKey{}).(DXDatabaseStatementKind)
+	if (!ok) || (kind == StatementKindAuto) {
+		kind = ClassifyStatementKind(statement)
+	}
+	return kind
+}
+
+// StatementContext returns ctx with the configured read_timeout or write_timeout applied, chosen by statement kind.
+// ctx is returned unchanged when it already has a deadline or when the matching timeout is not configured.
+func (d *DXDatabase) StatementContext(ctx context.Context, statement string) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok {
+		return ctx, func() {}
+	}
+	kind := statementKind(ctx, statement)
+	timeoutSec := d.WriteTimeoutSec
+	if kind == StatementKindRead {
+		timeoutSec = d.ReadTimeoutSec
+	}
+	if timeoutSec <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, time.Duration(timeoutSec)*time.Second)
+}
@@ -0,0 +1,119 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/donnyhardyanto/dxlib/log"
+)
+
+var ErrDatabaseInvalidTenantSchema = errors.New(`DATABASE_INVALID_TENANT_SCHEMA`)
+
+var tenantSchemaRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]{0,62}$`)
+
+type tenantConnContextKey struct{}
+
+type dxDatabaseTenantConn struct {
+	database    *DXDatabase
+	conn        *sqlx.Conn
+	releaseOnce sync.Once
+}
+
+// dxDatabaseSession is the part of *sqlx.DB and *sqlx.Conn used to run statements, so a tenant bound connection can be
+// used in place of the pool.
+type dxDatabaseSession interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryxContext(ctx context.Context, query string, args ...any) (*sqlx.Rows, error)
+}
+
+// WithTenant acquires a dedicated connection, switches it to schema (search_path on Postgres, the current database on
+// MySQL) and binds it to the returned context. Exec, Query and StreamQuery called with that context run on it. The
+// connection is reset and returned to the pool by ReleaseTenant, or when the context is done.
+func (d *DXDatabase) WithTenant(ctx context.Context, schema string) (context.Context, error) {
+	if d.Connection == nil {
+		return ctx, fmt.Errorf(`%w:%s`, ErrDatabaseNotConnected, d.NameId)
+	}
+	if !tenantSchemaRegexp.MatchString(schema) {
+		return ctx, fmt.Errorf(`%w:%s`, ErrDatabaseInvalidTenantSchema, schema)
+	}
+	var statement string
+	switch d.Connection.DriverName() {
+	case "postgres":
+		statement = `SET search_path TO "` + schema + `"`
+	case "mysql":
+		statement = "USE `" + schema + "`"
+	default:
+		err := log.Log.ErrorAndCreateErrorf("WithTenant is not supported for database driver %s", d.Connection.DriverName())
+		return ctx, err
+	}
+	conn, err := d.Connection.Connx(ctx)
+	if err != nil {
+		log.Log.Errorf("Database %s cannot acquire connection for tenant %s (%s)", d.NameId, schema, err.Error())
+		return ctx, err
+	}
+	_, err = conn.ExecContext(ctx, statement)
+	if err != nil {
+		log.Log.Errorf("Database %s cannot switch to tenant %s (%s)", d.NameId, schema, err.Error())
+		_ = conn.Close()
+		return ctx, err
+	}
+	tenantConn := &dxDatabaseTenantConn{database: d, conn: conn}
+	context.AfterFunc(ctx, func() {
+		_ = tenantConn.release()
+	})
+	return context.WithValue(WithTenant(ctx, schema), tenantConnContextKey{}, tenantConn), nil
+}
+
+// ReleaseTenant resets the tenant connection bound to ctx by WithTenant and returns it to the pool. It does nothing when
+// ctx has no tenant connection of this database.
+func (d *DXDatabase) ReleaseTenant(ctx context.Context) (err error) {
+	tenantConn, ok := ctx.Value(tenantConnContextKey{}).(*dxDatabaseTenantConn)
+	if !ok || (tenantConn.database != d) {
+		return nil
+	}
+	return tenantConn.release()
+}
+
+// release restores the default schema before the connection goes back to the pool. A connection that cannot be reset
+// is discarded instead, so no other request ever sees the tenant schema.
+func (tc *dxDatabaseTenantConn) release() (err error) {
+	tc.releaseOnce.Do(func() {
+		d := tc.database
+		var statement string
+		switch d.Connection.DriverName() {
+		case "postgres":
+			statement = `RESET search_path`
+		case "mysql":
+			statement = "USE `" + d.DatabaseName + "`"
+		}
+		_, err = tc.conn.ExecContext(context.Background(), statement)
+		if err != nil {
+			log.Log.Errorf("Database %s cannot reset tenant connection, discarding it (%s)", d.NameId, err.Error())
+			_ = tc.conn.Raw(func(any) error {
+				return driver.ErrBadConn
+			})
+		}
+		closeErr := tc.conn.Close()
+		if err == nil {
+			err = closeErr
+		}
+	})
+	return err
+}
+
+// session returns the tenant connection bound to ctx by WithTenant, or the pool.
+func (d *DXDatabase) session(ctx context.Context) dxDatabaseSession {
+	tenantConn, ok := ctx.Value(tenantConnContextKey{}).(*dxDatabaseTenantConn)
+	if ok && (tenantConn.database == d) {
+		return tenantConn.conn
+	}
+	return d.Connection
+}
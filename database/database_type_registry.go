@@ -0,0 +1,227 @@
+package database
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/jmoiron/sqlx/reflectx"
+
+	"github.com/donnyhardyanto/dxlib/utils"
+)
+
+type DXDatabaseTypeScanFunc func(b []byte) (v any, err error)
+
+type DXDatabaseTypeValueFunc func(v any) (b []byte, err error)
+
+type dxDatabaseTypeMapping struct {
+	scan  DXDatabaseTypeScanFunc
+	value DXDatabaseTypeValueFunc
+}
+
+// DXTypedValue is a statement argument bound through the value function registered for SQLType.
+type DXTypedValue struct {
+	SQLType string
+	Value   any
+}
+
+// RegisterType teaches the row scanning, to JSON (StreamQuery, QueryToChannel, QueryToCSV, QueryTimed) and to structs
+// (QueryStruct, QueryFirst, QueryMapByKey), to convert columns of sqlType with scan, and statement arguments wrapped in
+// DXTypedValue{SQLType: sqlType} with value. Either function may be nil. sqlType is matched, case insensitively,
+// against the type name reported by the driver. lib/pq reports no name for types it does not know, like enums or
+// PostGIS types; such columns are resolved by name with RegisterColumnType or RegisterTableTypes. Types must be
+// registered before the instance is used.
+func (d *DXDatabase) RegisterType(sqlType string, scan DXDatabaseTypeScanFunc, value DXDatabaseTypeValueFunc) {
+	if d.typeMappings == nil {
+		d.typeMappings = map[string]dxDatabaseTypeMapping{}
+	}
+	d.typeMappings[strings.ToUpper(sqlType)] = dxDatabaseTypeMapping{scan: scan, value: value}
+}
+
+// RegisterColumnType makes result columns named columnName use the mapping registered for sqlType when the driver
+// reports no type name or one without mapping. Column names are matched case insensitively, whatever table they come
+// from.
+func (d *DXDatabase) RegisterColumnType(columnName string, sqlType string) {
+	if d.columnTypes == nil {
+		d.columnTypes = map[string]string{}
+	}
+	d.columnTypes[strings.ToLower(columnName)] = strings.ToUpper(sqlType)
+}
+
+// RegisterTableTypes calls RegisterColumnType for every column of tableName whose type, UDTName or DataType from
+// Columns, has a registered mapping, so enum and geometry columns of the table resolve without naming them one by one.
+// Call it after RegisterType.
+func (d *DXDatabase) RegisterTableTypes(tableName string) (err error) {
+	columns, err := d.Columns(tableName)
+	if err != nil {
+		return err
+	}
+	for _, c := range columns {
+		for _, sqlType := range []string{c.UDTName, c.DataType} {
+			if _, ok := d.typeMappings[strings.ToUpper(sqlType)]; ok {
+				d.RegisterColumnType(c.Name, sqlType)
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// bindArgs replaces every DXTypedValue of args by the bytes of its registered value function.
+func (d *DXDatabase) bindArgs(args []any) (boundArgs []any, err error) {
+	boundArgs = args
+	copied := false
+	for i, arg := range args {
+		typedValue, ok := arg.(DXTypedValue)
+		if !ok {
+			continue
+		}
+		mapping, ok := d.typeMappings[strings.ToUpper(typedValue.SQLType)]
+		if !ok || (mapping.value == nil) {
+			return nil, fmt.Errorf("database %s has no value function registered for type %s", d.NameId, typedValue.SQLType)
+		}
+		b, err := mapping.value(typedValue.Value)
+		if err != nil {
+			return nil, fmt.Errorf("cannot bind value of type %s (%w)", typedValue.SQLType, err)
+		}
+		if !copied {
+			boundArgs = append([]any{}, args...)
+			copied = true
+		}
+		boundArgs[i] = b
+	}
+	return boundArgs, nil
+}
+
+// rowScanFuncs returns, by column name as scanned by MapScan, the registered scan function of the result columns. It is
+// nil when no column has one.
+func (d *DXDatabase) rowScanFuncs(rows *sqlx.Rows) (scanFuncs map[string]DXDatabaseTypeScanFunc, err error) {
+	if len(d.typeMappings) == 0 {
+		return nil, nil
+	}
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+	for _, columnType := range columnTypes {
+		mapping, ok := d.typeMappings[strings.ToUpper(columnType.DatabaseTypeName())]
+		if !ok {
+			sqlType, isRegistered := d.columnTypes[strings.ToLower(columnType.Name())]
+			if isRegistered {
+				mapping, ok = d.typeMappings[sqlType]
+			}
+		}
+		if !ok || (mapping.scan == nil) {
+			continue
+		}
+		if scanFuncs == nil {
+			scanFuncs = map[string]DXDatabaseTypeScanFunc{}
+		}
+		scanFuncs[columnType.Name()] = mapping.scan
+	}
+	return scanFuncs, nil
+}
+
+// applyScanFuncs converts the raw values of rowJSON, still keyed by the driver column names, with scanFuncs.
+func applyScanFuncs(rowJSON utils.JSON, scanFuncs map[string]DXDatabaseTypeScanFunc) (err error) {
+	for name, scan := range scanFuncs {
+		var b []byte
+		switch v := rowJSON[name].(type) {
+		case nil:
+			continue
+		case []byte:
+			b = v
+		case string:
+			b = []byte(v)
+		default:
+			continue
+		}
+		rowJSON[name], err = scan(b)
+		if err != nil {
+			return fmt.Errorf("cannot scan column %s (%w)", name, err)
+		}
+	}
+	return nil
+}
+
+// structScan is rows.StructScan with the columns having a scan function in scanFuncs read raw, converted and assigned
+// to their field. The converted value must be assignable or convertible to the field type, or to its element type for
+// a pointer field.
+func structScan(rows *sqlx.Rows, dest any, scanFuncs map[string]DXDatabaseTypeScanFunc) (err error) {
+	if len(scanFuncs) == 0 {
+		return rows.StructScan(dest)
+	}
+	v := reflect.ValueOf(dest)
+	if (v.Kind() != reflect.Pointer) || v.IsNil() {
+		return fmt.Errorf("struct scan destination must be a non-nil pointer, got %T", dest)
+	}
+	v = v.Elem()
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	traversals := rows.Mapper.TraversalsByName(v.Type(), columns)
+	values := make([]any, len(columns))
+	for i, column := range columns {
+		if len(traversals[i]) == 0 {
+			return fmt.Errorf("missing destination name %s in %T", column, dest)
+		}
+		if _, ok := scanFuncs[column]; ok {
+			values[i] = new(any)
+			continue
+		}
+		values[i] = reflectx.FieldByIndexes(v, traversals[i]).Addr().Interface()
+	}
+	err = rows.Scan(values...)
+	if err != nil {
+		return err
+	}
+	for i, column := range columns {
+		scan, ok := scanFuncs[column]
+		if !ok {
+			continue
+		}
+		converted := *(values[i].(*any))
+		switch raw := converted.(type) {
+		case nil:
+			continue
+		case []byte:
+			converted, err = scan(raw)
+		case string:
+			converted, err = scan([]byte(raw))
+		}
+		if err != nil {
+			return fmt.Errorf("cannot scan column %s (%w)", column, err)
+		}
+		err = assignScannedValue(reflectx.FieldByIndexes(v, traversals[i]), converted)
+		if err != nil {
+			return fmt.Errorf("cannot assign column %s (%w)", column, err)
+		}
+	}
+	return nil
+}
+
+func assignScannedValue(field reflect.Value, value any) error {
+	rv := reflect.ValueOf(value)
+	if !rv.IsValid() {
+		field.Set(reflect.Zero(field.Type()))
+		return nil
+	}
+	target := field
+	if (field.Kind() == reflect.Pointer) && !rv.Type().AssignableTo(field.Type()) {
+		target = reflect.New(field.Type().Elem()).Elem()
+	}
+	switch {
+	case rv.Type().AssignableTo(target.Type()):
+		target.Set(rv)
+	case rv.Type().ConvertibleTo(target.Type()):
+		target.Set(rv.Convert(target.Type()))
+	default:
+		return fmt.Errorf("%T is not assignable to %s", value, field.Type())
+	}
+	if target != field {
+		field.Set(target.Addr())
+	}
+	return nil
+}
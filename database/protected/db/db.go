@@ -7,6 +7,9 @@ import (
 	databaseProtectedUtils "github.com/donnyhardyanto/dxlib/database/protected/utils"
 	"github.com/donnyhardyanto/dxlib/utils"
 	"github.com/jmoiron/sqlx"
+	"reflect"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -80,8 +83,354 @@ func ExcludeSQLExpression(kv utils.JSON, driverName string) (r utils.JSON) {
 	return r
 }
 
+// SQLExpression is a raw SQL fragment. Composed with With and Embed it is a whole query whose :name placeholders take
+// their values from Params, rendered by SQL.
 type SQLExpression struct {
 	Expression string
+	Params     utils.JSON
+	ctes       []sqlCTE
+	parts      []SQLExpression
+	sel        *sqlSelect
+	err        error
+}
+
+type sqlCTE struct {
+	name string
+	sub  SQLExpression
+}
+
+var ErrSQLExpressionInvalidName = errors.New(`SQL_EXPRESSION_INVALID_NAME`)
+var ErrSQLExpressionConflict = errors.New(`SQL_EXPRESSION_CONFLICT`)
+var ErrSQLExpressionNotSelect = errors.New(`SQL_EXPRESSION_NOT_SELECT`)
+var ErrSQLSortFieldNotAllowed = errors.New(`SQL_SORT_FIELD_NOT_ALLOWED`)
+var ErrSQLSortDirectionInvalid = errors.New(`SQL_SORT_DIRECTION_INVALID`)
+
+var sqlExpressionNameRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+func NewSQLExpression(expression string, params utils.JSON) SQLExpression {
+	return SQLExpression{Expression: expression, Params: params}
+}
+
+// With adds sub as the common table expression name, referenced by name in se. The WITH clause is rendered by SQL,
+// after the common table expressions of sub and of the embedded parts.
+func (se SQLExpression) With(name string, sub SQLExpression) SQLExpression {
+	se.ctes = append(append([]sqlCTE{}, se.ctes...), sqlCTE{name: name, sub: sub})
+	return se
+}
+
+// Subquery returns se in parentheses, to embed it with Embed as a derived table or an IN/EXISTS operand.
+func (se SQLExpression) Subquery() SQLExpression {
+	se.Expression = `(` + se.Expression + `)`
+	se.sel = nil
+	return se
+}
+
+// Embed replaces every {name} in se with part. The params and common table expressions of part are carried along.
+func (se SQLExpression) Embed(name string, part SQLExpression) SQLExpression {
+	se.Expression = strings.ReplaceAll(se.Expression, `{`+name+`}`, part.Expression)
+	se.parts = append(append([]SQLExpression{}, se.parts...), part)
+	se.sel = nil
+	return se
+}
+
+func (se SQLExpression) collect(ctes *[]sqlCTE, params utils.JSON) (err error) {
+	if se.err != nil {
+		return se.err
+	}
+	for _, cte := range se.ctes {
+		if !sqlExpressionNameRegexp.MatchString(cte.name) {
+			return fmt.Errorf(`%w:%s`, ErrSQLExpressionInvalidName, cte.name)
+		}
+		err = cte.sub.collect(ctes, params)
+		if err != nil {
+			return err
+		}
+		found := false
+		for _, c := range *ctes {
+			if c.name == cte.name {
+				if c.sub.Expression != cte.sub.Expression {
+					return fmt.Errorf(`%w:CTE_%s`, ErrSQLExpressionConflict, cte.name)
+				}
+				found = true
+			}
+		}
+		if !found {
+			*ctes = append(*ctes, cte)
+		}
+	}
+	for _, part := range se.parts {
+		err = part.collect(ctes, params)
+		if err != nil {
+			return err
+		}
+	}
+	for k, v := range se.Params {
+		if existing, ok := params[k]; ok && !reflect.DeepEqual(existing, v) {
+			return fmt.Errorf(`%w:PARAM_%s`, ErrSQLExpressionConflict, k)
+		}
+		params[k] = v
+	}
+	return nil
+}
+
+// SQL renders se with its WITH clause and returns the params of all composed expressions, for NamedQuery. The same
+// name used with different common table expressions or param values is an error.
+func (se SQLExpression) SQL() (query string, params utils.JSON, err error) {
+	ctes := []sqlCTE{}
+	params = utils.JSON{}
+	err = se.collect(&ctes, params)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(ctes) == 0 {
+		return se.Expression, params, nil
+	}
+	withParts := make([]string, 0, len(ctes))
+	for _, cte := range ctes {
+		withParts = append(withParts, cte.name+` AS (`+cte.sub.Expression+`)`)
+	}
+	return `WITH ` + strings.Join(withParts, `, `) + ` ` + se.Expression, params, nil
+}
+
+type sqlSelect struct {
+	tableName string
+	columns   []string
+	distinct  bool
+	where     []string
+	groupBy   []string
+	having    []string
+	orderBy   string
+}
+
+func (sel sqlSelect) render() string {
+	s := `SELECT `
+	if sel.distinct {
+		s = s + `DISTINCT `
+	}
+	if len(sel.columns) == 0 {
+		s = s + `*`
+	} else {
+		s = s + strings.Join(sel.columns, `, `)
+	}
+	s = s + ` FROM ` + sel.tableName
+	if len(sel.where) > 0 {
+		s = s + ` WHERE ` + strings.Join(sel.where, ` AND `)
+	}
+	if len(sel.groupBy) > 0 {
+		s = s + ` GROUP BY ` + strings.Join(sel.groupBy, `, `)
+	}
+	if len(sel.having) > 0 {
+		s = s + ` HAVING ` + strings.Join(sel.having, ` AND `)
+	}
+	if sel.orderBy != `` {
+		s = s + ` ORDER BY ` + sel.orderBy
+	}
+	return s
+}
+
+// NewSelectSQLExpression builds SELECT columns FROM tableName, all columns when columns is empty. Every where entry is
+// AND-ed as name = :name, name IS NULL for a nil value, or the expression itself for an SQLExpression value. Columns
+// and tableName are SQL, never pass them from user input.
+func NewSelectSQLExpression(tableName string, columns []string, where utils.JSON) (se SQLExpression) {
+	sel := sqlSelect{tableName: tableName, columns: append([]string{}, columns...)}
+	se.Params = utils.JSON{}
+	keys := make([]string, 0, len(where))
+	for k := range where {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		switch v := where[k].(type) {
+		case nil:
+			sel.where = append(sel.where, k+` IS NULL`)
+		case SQLExpression:
+			sel.where = append(sel.where, v.Expression)
+			se.parts = append(se.parts, v)
+		default:
+			if !sqlExpressionNameRegexp.MatchString(k) {
+				se.err = fmt.Errorf(`%w:%s`, ErrSQLExpressionInvalidName, k)
+			}
+			sel.where = append(sel.where, k+` = :`+k)
+			se.Params[k] = v
+		}
+	}
+	return se.withSelect(sel)
+}
+
+func (se SQLExpression) withSelect(sel sqlSelect) SQLExpression {
+	se.sel = &sel
+	se.Expression = sel.render()
+	return se
+}
+
+func (se SQLExpression) selectCopy() (sel sqlSelect, ok bool) {
+	if se.sel == nil {
+		return sel, false
+	}
+	sel = *se.sel
+	sel.where = append([]string{}, sel.where...)
+	sel.groupBy = append([]string{}, sel.groupBy...)
+	sel.having = append([]string{}, sel.having...)
+	return sel, true
+}
+
+// SQLInCondition is the value WhereIn binds to a placeholder standing for the whole condition, expanded by the
+// database package for its driver: = ANY of an array on PostgreSQL, IN lists of at most 1000 values OR-ed on Oracle and
+// one IN list elsewhere. No values is a condition that is always false.
+type SQLInCondition struct {
+	Column string
+	Values []any
+}
+
+var sqlInColumnRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// WhereIn adds column IN values, AND-ed, to the where of the select built by NewSelectSQLExpression.
+func (se SQLExpression) WhereIn(column string, values []any) SQLExpression {
+	return se.whereCondition(`in_`, column, SQLInCondition{Column: column, Values: append([]any{}, values...)})
+}
+
+// whereCondition adds a placeholder standing for the whole condition on column, AND-ed, to the where of the select
+// built by NewSelectSQLExpression, with condition as its value.
+func (se SQLExpression) whereCondition(namePrefix string, column string, condition any) SQLExpression {
+	sel, ok := se.selectCopy()
+	if !ok {
+		se.err = ErrSQLExpressionNotSelect
+		return se
+	}
+	if !sqlInColumnRegexp.MatchString(column) {
+		se.err = fmt.Errorf(`%w:%s`, ErrSQLExpressionInvalidName, column)
+		return se
+	}
+	params := utils.JSON{}
+	for k, v := range se.Params {
+		params[k] = v
+	}
+	name := namePrefix + strings.ReplaceAll(column, `.`, `_`)
+	for i := 1; ; i++ {
+		if _, exists := params[name]; !exists {
+			break
+		}
+		name = namePrefix + strings.ReplaceAll(column, `.`, `_`) + `_` + strconv.Itoa(i)
+	}
+	params[name] = condition
+	se.Params = params
+	sel.where = append(sel.where, `:`+name)
+	return se.withSelect(sel)
+}
+
+const (
+	SQLJSONBOperatorContains = `@>`
+	SQLJSONBOperatorHasKey   = `?`
+)
+
+// SQLJSONBCondition is the value JSONBContains and JSONBHasKey bind to a placeholder standing for the whole condition,
+// expanded by the database package. It is PostgreSQL only, other drivers fail with an unsupported error.
+type SQLJSONBCondition struct {
+	Column   string
+	Operator string
+	Value    any
+}
+
+// JSONBContains adds column @> value, AND-ed, to the where of the select built by NewSelectSQLExpression. value is
+// bound marshalled as jsonb.
+func (se SQLExpression) JSONBContains(column string, value utils.JSON) SQLExpression {
+	return se.whereCondition(`jsonb_`, column, SQLJSONBCondition{Column: column, Operator: SQLJSONBOperatorContains, Value: value})
+}
+
+// JSONBHasKey adds column ? key, AND-ed, to the where of the select built by NewSelectSQLExpression: key is a top
+// level key of the jsonb object column.
+func (se SQLExpression) JSONBHasKey(column string, key string) SQLExpression {
+	return se.whereCondition(`jsonb_`, column, SQLJSONBCondition{Column: column, Operator: SQLJSONBOperatorHasKey, Value: key})
+}
+
+// SQLPartOrderByAllowlist turns the client supplied sortSpec into an ORDER BY list. sortSpec is comma separated fields,
+// each "field", "field asc", "field desc" or "-field" for descending. Fields are API names looked up in allowlist, which
+// maps them to the real column, an unknown field is ErrSQLSortFieldNotAllowed. An empty sortSpec is an empty list.
+func SQLPartOrderByAllowlist(sortSpec string, allowlist map[string]string) (s string, err error) {
+	orderByParts := []string{}
+	for _, item := range strings.Split(sortSpec, `,`) {
+		fields := strings.Fields(item)
+		if len(fields) == 0 {
+			continue
+		}
+		field := fields[0]
+		direction := `ASC`
+		if strings.HasPrefix(field, `-`) {
+			field = field[1:]
+			direction = `DESC`
+		}
+		switch len(fields) {
+		case 1:
+		case 2:
+			switch strings.ToUpper(fields[1]) {
+			case `ASC`:
+			case `DESC`:
+				direction = `DESC`
+			default:
+				return ``, fmt.Errorf(`%w:%s`, ErrSQLSortDirectionInvalid, fields[1])
+			}
+		default:
+			return ``, fmt.Errorf(`%w:%s`, ErrSQLSortDirectionInvalid, strings.TrimSpace(item))
+		}
+		column, ok := allowlist[field]
+		if !ok {
+			return ``, fmt.Errorf(`%w:%s`, ErrSQLSortFieldNotAllowed, field)
+		}
+		orderByParts = append(orderByParts, column+` `+direction)
+	}
+	return strings.Join(orderByParts, `, `), nil
+}
+
+// OrderByAllowlist sets the ORDER BY of the select built by NewSelectSQLExpression from the client supplied sortSpec,
+// see SQLPartOrderByAllowlist.
+func (se SQLExpression) OrderByAllowlist(sortSpec string, allowlist map[string]string) SQLExpression {
+	sel, ok := se.selectCopy()
+	if !ok {
+		se.err = ErrSQLExpressionNotSelect
+		return se
+	}
+	orderBy, err := SQLPartOrderByAllowlist(sortSpec, allowlist)
+	if err != nil {
+		se.err = err
+		return se
+	}
+	sel.orderBy = orderBy
+	return se.withSelect(sel)
+}
+
+// Distinct makes the select built by NewSelectSQLExpression SELECT DISTINCT.
+func (se SQLExpression) Distinct() SQLExpression {
+	sel, ok := se.selectCopy()
+	if !ok {
+		se.err = ErrSQLExpressionNotSelect
+		return se
+	}
+	sel.distinct = true
+	return se.withSelect(sel)
+}
+
+// GroupBy adds columns to the GROUP BY of the select built by NewSelectSQLExpression.
+func (se SQLExpression) GroupBy(columns ...string) SQLExpression {
+	sel, ok := se.selectCopy()
+	if !ok {
+		se.err = ErrSQLExpressionNotSelect
+		return se
+	}
+	sel.groupBy = append(sel.groupBy, columns...)
+	return se.withSelect(sel)
+}
+
+// Having adds condition, AND-ed, to the HAVING of the select built by NewSelectSQLExpression. Its :name placeholders
+// take their values from params.
+func (se SQLExpression) Having(condition string, params utils.JSON) SQLExpression {
+	sel, ok := se.selectCopy()
+	if !ok {
+		se.err = ErrSQLExpressionNotSelect
+		return se
+	}
+	sel.having = append(sel.having, condition)
+	se.parts = append(append([]SQLExpression{}, se.parts...), NewSQLExpression(condition, params))
+	return se.withSelect(sel)
 }
 
 func (se SQLExpression) String() (s string) {
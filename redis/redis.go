@@ -6,6 +6,9 @@ import (
 	"errors"
 	"fmt"
 	"github.com/go-redis/redis/v8"
+	"math/rand"
+	"os"
+	"strings"
 	"time"
 
 	dxlibv3Configuration "github.com/donnyhardyanto/dxlib/configuration"
@@ -16,24 +19,53 @@ import (
 )
 
 type DXRedis struct {
-	Owner            *DXRedisManager
-	NameId           string
-	IsConfigured     bool
-	Address          string
-	UserName         string
-	HasUserName      bool
-	Password         string
-	HasPassword      bool
-	DatabaseIndex    int
-	IsConnectAtStart bool
-	MustConnected    bool
-	Connection       *redis.Ring
-	Connected        bool
-	Context          context.Context
+	Owner                 *DXRedisManager
+	NameId                string
+	IsConfigured          bool
+	Address               string
+	UserName              string
+	HasUserName           bool
+	Password              string
+	HasPassword           bool
+	DatabaseIndex         int
+	SchemaVersion         int
+	MaxValueSize          int
+	OnOversize            string
+	TTLJitter             float64
+	MaxRetries            int
+	RetryBudget           *DXRedisRetryBudget
+	ClientName            string
+	CommandHooks          []DXRedisHook
+	ConnectRetries        int
+	ConnectRetryDelay     time.Duration
+	L1Cache               *DXRedisL1Cache
+	L1InvalidationChannel string
+	KeyKinds              *DXRedisKeyKinds
+	Compression           string
+	CompressionThreshold  int
+	IsConnectAtStart      bool
+	MustConnected         bool
+	Connection            *redis.Ring
+	Connected             bool
+	Context               context.Context
 }
 
+// RedisSchemaVersionHeaderMagic marks a value written with a schema version header. JSON never starts with a NUL byte, so
+// values without it are legacy values and treated as schema version 0.
+const RedisSchemaVersionHeaderMagic byte = 0x00
+
+const RedisSchemaVersionMax = 255
+
+const RedisRingShardName = "shard1"
+
+const (
+	RedisOnOversizeWarn   = "warn"
+	RedisOnOversizeReject = "reject"
+)
+
 type DXRedisManager struct {
 	Redises map[string]*DXRedis
+	Scripts map[string]*redis.Script
 }
 
 func (rs *DXRedisManager) NewRedis(nameId string, isConnectAtStart, mustConnected bool) *DXRedis {
@@ -47,12 +79,45 @@ func (rs *DXRedisManager) NewRedis(nameId string, isConnectAtStart, mustConnecte
 		HasUserName:      false,
 		HasPassword:      false,
 		DatabaseIndex:    0,
+		SchemaVersion:    0,
+		MaxValueSize:     0,
+		OnOversize:       RedisOnOversizeWarn,
+		TTLJitter:        0,
+		MaxRetries:       RedisDefaultMaxRetries,
+		RetryBudget:      NewDXRedisRetryBudget(RedisDefaultRetryBudgetRatio, RedisDefaultRetryBudgetMaxTokens),
 		Context:          core.RootContext,
 	}
 	rs.Redises[nameId] = &r
 	return &r
 }
 
+// NewRedisFromConfig creates and configures a Redis instance from an inline configuration map, without going through
+// the configuration manager.
+func (rs *DXRedisManager) NewRedisFromConfig(nameId string, cfg utils.JSON) (*DXRedis, error) {
+	isConnectAtStart, ok := cfg[`is_connect_at_start`].(bool)
+	if !ok {
+		isConnectAtStart = false
+	}
+	mustConnected, ok := cfg[`must_connected`].(bool)
+	if !ok {
+		mustConnected = false
+	}
+	r := rs.NewRedis(nameId, isConnectAtStart, mustConnected)
+	log.Log.Infof("Configuring to Redis %s... start", r.NameId)
+	err := r.ApplyConfigurationData(cfg)
+	if err != nil {
+		delete(rs.Redises, nameId)
+		return nil, err
+	}
+	log.Log.Infof("Configuring to Redis %s... done", r.NameId)
+	return r, nil
+}
+
+// RegisterScript registers a Lua script under nameId, to be run on any Redis instance with RunScript.
+func (rs *DXRedisManager) RegisterScript(nameId string, src string) {
+	rs.Scripts[nameId] = redis.NewScript(src)
+}
+
 func (rs *DXRedisManager) LoadFromConfiguration(configurationNameId string) (err error) {
 	configuration, ok := dxlibv3Configuration.Manager.Configurations[configurationNameId]
 	if !ok {
@@ -139,34 +204,104 @@ func (r *DXRedis) ApplyFromConfiguration() (err error) {
 				return err
 			}
 		}
-		r.Address, ok = redisConfiguration[`address`].(string)
-		if !ok {
-			if r.MustConnected {
-				err := log.Log.PanicAndCreateErrorf("Mandatory address field in Redis %s configuration not exist", r.NameId)
-				return err
-			} else {
-				err := log.Log.WarnAndCreateErrorf("configuration is unusable, mandatory address field in Redis %s configuration not exist", r.NameId)
-				return err
-			}
-		}
-		r.UserName, r.HasUserName = redisConfiguration[`user_name`].(string)
-		r.Password, r.HasPassword = redisConfiguration[`password`].(string)
-		r.DatabaseIndex, err = json2.GetInt(redisConfiguration, `database_index`)
+		err = r.ApplyConfigurationData(redisConfiguration)
 		if err != nil {
-			if r.MustConnected {
-				err := log.Log.PanicAndCreateErrorf("Mandatory database_index field in Redis %s configuration not exist, check configuration and make sure it was integer not a string", r.NameId)
-				return err
-			} else {
-				err := log.Log.WarnAndCreateErrorf("configuration is unusable, mandatory address field in Redis %s configuration not exist", r.NameId)
-				return err
-			}
+			return err
 		}
-		r.IsConfigured = true
 		log.Log.Infof("Configuring to Redis %s... done", r.NameId)
 	}
 	return nil
 }
 
+// ApplyConfigurationData configures the instance from a single Redis configuration map, the same fields as one entry
+// of the redis configuration.
+func (r *DXRedis) ApplyConfigurationData(redisConfiguration utils.JSON) (err error) {
+	ok := false
+	r.Address, ok = redisConfiguration[`address`].(string)
+	if !ok {
+		if r.MustConnected {
+			err := log.Log.ErrorAndCreateErrorf("Mandatory address field in Redis %s configuration not exist", r.NameId)
+			return err
+		} else {
+			err := log.Log.WarnAndCreateErrorf("configuration is unusable, mandatory address field in Redis %s configuration not exist", r.NameId)
+			return err
+		}
+	}
+	r.UserName, r.HasUserName = redisConfiguration[`user_name`].(string)
+	r.Password, r.HasPassword = redisConfiguration[`password`].(string)
+	r.DatabaseIndex, err = json2.GetInt(redisConfiguration, `database_index`)
+	if err != nil {
+		if r.MustConnected {
+			err := log.Log.ErrorAndCreateErrorf("Mandatory database_index field in Redis %s configuration not exist, check configuration and make sure it was integer not a string", r.NameId)
+			return err
+		} else {
+			err := log.Log.WarnAndCreateErrorf("configuration is unusable, mandatory address field in Redis %s configuration not exist", r.NameId)
+			return err
+		}
+	}
+	r.SchemaVersion, _ = json2.GetIntWithDefault(redisConfiguration, `schema_version`, 0)
+	if (r.SchemaVersion < 0) || (r.SchemaVersion > RedisSchemaVersionMax) {
+		err := log.Log.WarnAndCreateErrorf("configuration is unusable, schema_version field in Redis %s configuration must be between 0 and %d", r.NameId, RedisSchemaVersionMax)
+		return err
+	}
+	r.MaxValueSize, _ = json2.GetIntWithDefault(redisConfiguration, `max_value_size`, 0)
+	r.OnOversize, ok = redisConfiguration[`on_oversize`].(string)
+	if !ok {
+		r.OnOversize = RedisOnOversizeWarn
+	}
+	if (r.OnOversize != RedisOnOversizeWarn) && (r.OnOversize != RedisOnOversizeReject) {
+		err := log.Log.WarnAndCreateErrorf("configuration is unusable, on_oversize field in Redis %s configuration must be %s or %s", r.NameId, RedisOnOversizeWarn, RedisOnOversizeReject)
+		return err
+	}
+	r.Compression, ok = redisConfiguration[`compression`].(string)
+	if !ok {
+		r.Compression = RedisCompressionNone
+	}
+	if (r.Compression != RedisCompressionNone) && (r.Compression != RedisCompressionAlways) && (r.Compression != RedisCompressionAuto) {
+		err := log.Log.WarnAndCreateErrorf("configuration is unusable, compression field in Redis %s configuration must be %s, %s or %s", r.NameId, RedisCompressionNone, RedisCompressionAlways, RedisCompressionAuto)
+		return err
+	}
+	r.CompressionThreshold, _ = json2.GetIntWithDefault(redisConfiguration, `compression_threshold`, RedisDefaultCompressionThreshold)
+	r.TTLJitter = json2.GetNumberWithDefault(redisConfiguration, `ttl_jitter`, 0.0)
+	if (r.TTLJitter < 0) || (r.TTLJitter >= 1) {
+		err := log.Log.WarnAndCreateErrorf("configuration is unusable, ttl_jitter field in Redis %s configuration must be between 0 and less than 1", r.NameId)
+		return err
+	}
+	r.MaxRetries, _ = json2.GetIntWithDefault(redisConfiguration, `max_retries`, RedisDefaultMaxRetries)
+	retryBudgetRatio := json2.GetNumberWithDefault(redisConfiguration, `retry_budget_ratio`, RedisDefaultRetryBudgetRatio)
+	retryBudgetMaxTokens := json2.GetNumberWithDefault(redisConfiguration, `retry_budget_max_tokens`, float64(RedisDefaultRetryBudgetMaxTokens))
+	r.RetryBudget = NewDXRedisRetryBudget(retryBudgetRatio, retryBudgetMaxTokens)
+	r.ConnectRetries, _ = json2.GetIntWithDefault(redisConfiguration, `connect_retries`, 0)
+	connectRetryDelaySec := json2.GetNumberWithDefault(redisConfiguration, `connect_retry_delay`, 1.0)
+	r.ConnectRetryDelay = time.Duration(connectRetryDelaySec * float64(time.Second))
+	l1Size, _ := json2.GetIntWithDefault(redisConfiguration, `l1_size`, 0)
+	l1TTLSec := json2.GetNumberWithDefault(redisConfiguration, `l1_ttl`, 1.0)
+	r.L1Cache = nil
+	if l1Size > 0 {
+		r.L1Cache = NewDXRedisL1Cache(l1Size, time.Duration(l1TTLSec*float64(time.Second)))
+	}
+	r.L1InvalidationChannel, _ = redisConfiguration[`l1_invalidation_channel`].(string)
+	r.KeyKinds = nil
+	strictTypes, _ := redisConfiguration[`strict_types`].(bool)
+	if strictTypes {
+		r.KeyKinds = NewDXRedisKeyKinds()
+	}
+	r.ClientName, ok = redisConfiguration[`client_name`].(string)
+	if !ok {
+		r.ClientName = r.defaultClientName()
+	}
+	r.IsConfigured = true
+	return nil
+}
+
+func (r *DXRedis) defaultClientName() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	return strings.ReplaceAll(fmt.Sprintf("%s-%s-%d", r.NameId, hostname, os.Getpid()), " ", "_")
+}
+
 func (r *DXRedis) Connect() (err error) {
 	if !r.Connected {
 		err := r.ApplyFromConfiguration()
@@ -175,20 +310,23 @@ func (r *DXRedis) Connect() (err error) {
 			return err
 		}
 		log.Log.Infof("Connecting to Redis %s at %s/%d... start", r.NameId, r.Address, r.DatabaseIndex)
-		redisRingOptions := &redis.RingOptions{
-			Addrs: map[string]string{
-				"shard1": r.Address,
-			},
-			DB: r.DatabaseIndex,
-		}
-		if r.HasUserName {
-			redisRingOptions.Username = r.UserName
+		connection := r.newRing()
+		err = connection.Ping(r.Context).Err()
+		for attempt := 0; (err != nil) && !isContextError(err) && (attempt < r.ConnectRetries); attempt++ {
+			delay := r.ConnectRetryDelay << attempt
+			log.Log.Warnf("Cannot connect to Redis %s at %s/%d, retry %d/%d in %v (%s)", r.NameId, r.Address, r.DatabaseIndex, attempt+1, r.ConnectRetries, delay, err.Error())
+			select {
+			case <-r.Context.Done():
+				err = r.Context.Err()
+			case <-time.After(delay):
+				err = connection.Ping(r.Context).Err()
+			}
 		}
-		if r.HasPassword {
-			redisRingOptions.Password = r.Password
+		if (err != nil) && isContextError(err) {
+			_ = connection.Close()
+			log.Log.Errorf("Connecting to Redis %s at %s/%d aborted, context done before Redis answered (%s)", r.NameId, r.Address, r.DatabaseIndex, err.Error())
+			return err
 		}
-		connection := redis.NewRing(redisRingOptions)
-		err = connection.Ping(r.Context).Err()
 		if err != nil {
 			if r.MustConnected {
 				log.Log.Fatalf("Cannot connect to Redis %s at %s/%d (%s)", r.NameId, r.Address, r.DatabaseIndex, err.Error())
@@ -200,11 +338,66 @@ func (r *DXRedis) Connect() (err error) {
 		}
 		r.Connection = connection
 		r.Connected = true
+		if (r.L1Cache != nil) && (r.L1InvalidationChannel != "") {
+			go r.runL1InvalidationSubscriber()
+		}
 		log.Log.Infof("Connecting to Redis %s at %s/%d... done CONNECTED", r.NameId, r.Address, r.DatabaseIndex)
 	}
 	return nil
 }
 
+// newRing creates the Ring client for the instance address and DatabaseIndex, it does not connect yet.
+func (r *DXRedis) newRing() *redis.Ring {
+	redisRingOptions := &redis.RingOptions{
+		Addrs: map[string]string{
+			RedisRingShardName: r.Address,
+		},
+		DB: r.DatabaseIndex,
+		OnConnect: func(ctx context.Context, cn *redis.Conn) error {
+			if r.ClientName == "" {
+				return nil
+			}
+			err := cn.ClientSetName(ctx, r.ClientName).Err()
+			if err != nil {
+				log.Log.Warnf("Cannot set client name of Redis %s connection (%s) %s", r.NameId, err.Error(), r.ClientName)
+			}
+			return nil
+		},
+	}
+	if r.HasUserName {
+		redisRingOptions.Username = r.UserName
+	}
+	if r.HasPassword {
+		redisRingOptions.Password = r.Password
+	}
+	connection := redis.NewRing(redisRingOptions)
+	connection.AddHook(redisHookBridge{r: r})
+	return connection
+}
+
+// WithDatabase returns a view of the instance bound to database index, for occasional access to a sibling database.
+// SELECT cannot be used on the pooled Ring connections, so the view owns a separate client with its own small pool; it
+// connects lazily and must be released with Disconnect. The view has no L1 cache and is not registered in the manager.
+// Key kinds, when tracked, are tracked apart from the instance since the keys live in another database.
+func (r *DXRedis) WithDatabase(index int) *DXRedis {
+	view := *r
+	view.NameId = fmt.Sprintf("%s/%d", r.NameId, index)
+	view.DatabaseIndex = index
+	view.L1Cache = nil
+	view.L1InvalidationChannel = ""
+	if r.KeyKinds != nil {
+		view.KeyKinds = NewDXRedisKeyKinds()
+	}
+	view.Connection = view.newRing()
+	view.Connected = true
+	return &view
+}
+
+// isContextError reports whether err comes from the context deadline or cancellation rather than from Redis itself.
+func isContextError(err error) bool {
+	return errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled)
+}
+
 func (r *DXRedis) Ping() (err error) {
 	err = r.Connection.Ping(r.Context).Err()
 	if err != nil {
@@ -214,23 +407,106 @@ func (r *DXRedis) Ping() (err error) {
 	return nil
 }
 
+// Do runs an arbitrary command on the managed connection, for commands that have no wrapper yet.
+func (r *DXRedis) Do(args ...any) (any, error) {
+	result, err := r.Connection.Do(r.Context, args...).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, err
+		}
+		log.Log.Errorf("Error in command Redis %s (%v) %v", r.NameId, err, args)
+		return nil, err
+	}
+	return result, nil
+}
+
 func (r *DXRedis) Set(key string, value utils.JSON, expirationDuration time.Duration) (err error) {
+	err = r.checkKeyKind(key, RedisKeyKindJSON)
+	if err != nil {
+		return err
+	}
+	defer r.invalidateL1(key)
 	valueAsBytes, err := json.Marshal(value)
 	if err != nil {
 		log.Log.Errorf("Cannot save to Redis %s k/v (%v) %s/%v", r.NameId, err, key, value)
 		return err
 	}
 
-	err = r.Connection.Set(r.Context, key, valueAsBytes, expirationDuration).Err()
+	valueAsBytes = r.encodeValue(valueAsBytes)
+
+	err = r.checkValueSize(key, len(valueAsBytes))
+	if err != nil {
+		return err
+	}
+
+	err = r.withRetry(func() error {
+		return r.Connection.Set(r.Context, key, valueAsBytes, r.jitterTTL(expirationDuration)).Err()
+	})
 	if err != nil {
 		log.Log.Errorf("Cannot save to Redis %s k/v (%v) %s/%v", r.NameId, err, key, value)
 		return err
 	}
+	r.recordKeyKind(key, RedisKeyKindJSON)
 	return nil
 }
 
+// SetUntil is Set with the value expiring at the absolute time t, evaluated by the server clock (SET EXAT, Redis 6.2+),
+// without TTL jitter.
+func (r *DXRedis) SetUntil(key string, value utils.JSON, t time.Time) (err error) {
+	err = r.checkKeyKind(key, RedisKeyKindJSON)
+	if err != nil {
+		return err
+	}
+	defer r.invalidateL1(key)
+	valueAsBytes, err := json.Marshal(value)
+	if err != nil {
+		log.Log.Errorf("Cannot save to Redis %s k/v (%v) %s/%v", r.NameId, err, key, value)
+		return err
+	}
+	valueAsBytes = r.encodeValue(valueAsBytes)
+	err = r.checkValueSize(key, len(valueAsBytes))
+	if err != nil {
+		return err
+	}
+	err = r.withRetry(func() error {
+		return r.Connection.SetArgs(r.Context, key, valueAsBytes, redis.SetArgs{ExpireAt: t}).Err()
+	})
+	if err != nil {
+		log.Log.Errorf("Cannot save to Redis %s k/v (%v) %s/%v", r.NameId, err, key, value)
+		return err
+	}
+	r.recordKeyKind(key, RedisKeyKindJSON)
+	return nil
+}
+
+// ExpireAt makes key expire at the absolute time t with EXPIREAT and reports whether the key exists. A t in the past
+// deletes the key.
+func (r *DXRedis) ExpireAt(key string, t time.Time) (ok bool, err error) {
+	ok, err = r.Connection.ExpireAt(r.Context, key, t).Result()
+	if err != nil {
+		log.Log.Errorf("Cannot set expiration of key in Redis %s (%v) %s", r.NameId, err, key)
+		return false, err
+	}
+	return ok, nil
+}
+
 func (r *DXRedis) Get(key string) (value utils.JSON, err error) {
-	valueAsBytes, err := r.Connection.Get(r.Context, key).Bytes()
+	err = r.checkKeyKind(key, RedisKeyKindJSON)
+	if err != nil {
+		return nil, err
+	}
+	if r.L1Cache != nil {
+		payload, ok := r.L1Cache.Get(key)
+		if ok && (json.Unmarshal(payload, &value) == nil) {
+			return value, nil
+		}
+		value = nil
+	}
+	var valueAsBytes []byte
+	err = r.withRetry(func() (err error) {
+		valueAsBytes, err = r.Connection.Get(r.Context, key).Bytes()
+		return err
+	})
 	if err != nil {
 		if errors.Is(err, redis.Nil) {
 			return nil, nil
@@ -238,16 +514,46 @@ func (r *DXRedis) Get(key string) (value utils.JSON, err error) {
 		log.Log.Errorf("Cannot get to Redis %s k/v (%s) %s", r.NameId, err.Error(), key)
 		return nil, err
 	}
+	valueAsBytes, ok := r.decodeValue(valueAsBytes)
+	if !ok {
+		log.Log.Debugf("Schema version mismatch in Redis %s k/v, treated as miss %s", r.NameId, key)
+		return nil, nil
+	}
 	err = json.Unmarshal(valueAsBytes, &value)
 	if err != nil {
 		log.Log.Errorf("Cannot unmarshall from bytes in Redis %s k/v (%s) %s/%v", r.NameId, err.Error(), key, valueAsBytes)
 		return nil, err
 	}
+	if r.L1Cache != nil {
+		r.L1Cache.Set(key, valueAsBytes)
+	}
 	return value, nil
 }
 
+// GetOrDefault returns the cached value, or defaultValue on miss and on any backend error. Errors are only logged, use
+// it where the cache is an optimization and MustGet where the value is required.
+func (r *DXRedis) GetOrDefault(key string, defaultValue utils.JSON) utils.JSON {
+	value, err := r.Get(key)
+	if err != nil {
+		log.Log.Warnf("Fallback to default value for Redis %s k/v (%s) %s", r.NameId, err.Error(), key)
+		return defaultValue
+	}
+	if value == nil {
+		return defaultValue
+	}
+	return value
+}
+
 func (r *DXRedis) MustGet(key string) (value utils.JSON, err error) {
-	valueAsBytes, err := r.Connection.Get(r.Context, key).Bytes()
+	err = r.checkKeyKind(key, RedisKeyKindJSON)
+	if err != nil {
+		return nil, err
+	}
+	var valueAsBytes []byte
+	err = r.withRetry(func() (err error) {
+		valueAsBytes, err = r.Connection.Get(r.Context, key).Bytes()
+		return err
+	})
 	if err != nil {
 		if errors.Is(err, redis.Nil) {
 			log.Log.Errorf("Cannot find keyin Redis %s (%s) %s", r.NameId, err.Error(), key)
@@ -257,6 +563,11 @@ func (r *DXRedis) MustGet(key string) (value utils.JSON, err error) {
 			return nil, err
 		}
 	}
+	valueAsBytes, ok := r.decodeValue(valueAsBytes)
+	if !ok {
+		err = log.Log.ErrorAndCreateErrorf("Schema version mismatch in Redis %s k/v %s", r.NameId, key)
+		return nil, err
+	}
 	err = json.Unmarshal(valueAsBytes, &value)
 	if err != nil {
 		log.Log.Errorf("Cannot unmarshall from bytes in Redis %s k/v (%s) %s/%v", r.NameId, err.Error(), key, valueAsBytes)
@@ -265,8 +576,184 @@ func (r *DXRedis) MustGet(key string) (value utils.JSON, err error) {
 	return value, nil
 }
 
+// SetBytes stores b as is, without JSON marshalling and without schema version header. Values written with SetBytes
+// must be read back with GetBytes, not Get.
+func (r *DXRedis) SetBytes(key string, b []byte, expirationDuration time.Duration) (err error) {
+	defer r.invalidateL1(key)
+	err = r.checkValueSize(key, len(b))
+	if err != nil {
+		return err
+	}
+	err = r.withRetry(func() error {
+		return r.Connection.Set(r.Context, key, b, r.jitterTTL(expirationDuration)).Err()
+	})
+	if err != nil {
+		log.Log.Errorf("Cannot save bytes to Redis %s k/v (%v) %s", r.NameId, err, key)
+		return err
+	}
+	return nil
+}
+
+// GetBytes returns the raw value written by SetBytes, or nil, nil when the key does not exist.
+func (r *DXRedis) GetBytes(key string) (b []byte, err error) {
+	err = r.withRetry(func() (err error) {
+		b, err = r.Connection.Get(r.Context, key).Bytes()
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, nil
+		}
+		log.Log.Errorf("Cannot get bytes to Redis %s k/v (%s) %s", r.NameId, err.Error(), key)
+		return nil, err
+	}
+	return b, nil
+}
+
+// Dump returns the serialized value of key in the Redis DUMP format, or nil, nil when the key does not exist.
+func (r *DXRedis) Dump(key string) (data []byte, err error) {
+	data, err = r.Connection.Dump(r.Context, key).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, nil
+		}
+		log.Log.Errorf("Cannot dump key in Redis %s (%s) %s", r.NameId, err.Error(), key)
+		return nil, err
+	}
+	return data, nil
+}
+
+// Restore creates key from data produced by Dump, with ttl 0 meaning no expiration. Without replace an existing key
+// makes it fail with BUSYKEY.
+func (r *DXRedis) Restore(key string, ttl time.Duration, data []byte, replace bool) (err error) {
+	defer r.invalidateL1(key)
+	if replace {
+		err = r.Connection.RestoreReplace(r.Context, key, ttl, string(data)).Err()
+	} else {
+		err = r.Connection.Restore(r.Context, key, ttl, string(data)).Err()
+	}
+	if err != nil {
+		log.Log.Errorf("Cannot restore key in Redis %s (%s) %s", r.NameId, err.Error(), key)
+		return err
+	}
+	return nil
+}
+
+var redisCompareAndSwapScript = redis.NewScript(`
+local current = redis.call('GET', KEYS[1])
+if ARGV[4] == '1' then
+	if current then
+		return 0
+	end
+elseif current ~= ARGV[1] then
+	return 0
+end
+if tonumber(ARGV[3]) > 0 then
+	redis.call('SET', KEYS[1], ARGV[2], 'PX', ARGV[3])
+else
+	redis.call('SET', KEYS[1], ARGV[2])
+end
+return 1
+`)
+
+// CompareAndSwap atomically replaces the value of key with newValue only when the stored value equals expected, a nil
+// expected means the key must not exist yet. The comparison is done on the marshalled bytes inside a Lua script.
+// encoding/json marshals maps with keys sorted at every level, so values written by Set are already normalized, and
+// expected is normalized the same way before comparing. Values written by other clients with a different key order or
+// whitespace never match.
+func (r *DXRedis) CompareAndSwap(key string, expected, newValue utils.JSON, expirationDuration time.Duration) (swapped bool, err error) {
+	err = r.checkKeyKind(key, RedisKeyKindJSON)
+	if err != nil {
+		return false, err
+	}
+	defer r.invalidateL1(key)
+	expectedAsBytes := []byte{}
+	expectMissing := "1"
+	if expected != nil {
+		expectedAsBytes, err = json.Marshal(expected)
+		if err != nil {
+			log.Log.Errorf("Cannot compare and swap to Redis %s k/v (%v) %s/%v", r.NameId, err, key, expected)
+			return false, err
+		}
+		expectedAsBytes = r.encodeValue(expectedAsBytes)
+		expectMissing = "0"
+	}
+	newValueAsBytes, err := json.Marshal(newValue)
+	if err != nil {
+		log.Log.Errorf("Cannot compare and swap to Redis %s k/v (%v) %s/%v", r.NameId, err, key, newValue)
+		return false, err
+	}
+	newValueAsBytes = r.encodeValue(newValueAsBytes)
+	err = r.checkValueSize(key, len(newValueAsBytes))
+	if err != nil {
+		return false, err
+	}
+	n, err := redisCompareAndSwapScript.Run(r.Context, r.Connection, []string{key}, expectedAsBytes, newValueAsBytes, r.jitterTTL(expirationDuration).Milliseconds(), expectMissing).Int64()
+	if err != nil {
+		log.Log.Errorf("Cannot compare and swap to Redis %s k/v (%v) %s/%v", r.NameId, err, key, newValue)
+		return false, err
+	}
+	if n == 1 {
+		r.recordKeyKind(key, RedisKeyKindJSON)
+	}
+	return n == 1, nil
+}
+
+// checkValueSize enforces max_value_size before the value is sent. Depending on on_oversize an oversize value is
+// rejected with an error or only logged as warning.
+func (r *DXRedis) checkValueSize(key string, size int) (err error) {
+	if (r.MaxValueSize <= 0) || (size <= r.MaxValueSize) {
+		return nil
+	}
+	if r.OnOversize == RedisOnOversizeReject {
+		err = log.Log.ErrorAndCreateErrorf("Value too large to save to Redis %s k/v (%d > %d bytes) %s", r.NameId, size, r.MaxValueSize, key)
+		return err
+	}
+	log.Log.Warnf("Value too large saved to Redis %s k/v (%d > %d bytes) %s", r.NameId, size, r.MaxValueSize, key)
+	return nil
+}
+
+// jitterTTL randomizes expirationDuration within +/- ttl_jitter of itself, so keys written with the same TTL do not
+// all expire at the same moment. No expiration and zero jitter are returned unchanged.
+func (r *DXRedis) jitterTTL(expirationDuration time.Duration) time.Duration {
+	if (expirationDuration <= 0) || (r.TTLJitter <= 0) {
+		return expirationDuration
+	}
+	delta := (rand.Float64()*2 - 1) * r.TTLJitter * float64(expirationDuration)
+	jittered := expirationDuration + time.Duration(delta)
+	if jittered < time.Millisecond {
+		jittered = time.Millisecond
+	}
+	return jittered
+}
+
+// encodeSchemaVersion prepends the schema version header when the instance has a non-zero schema_version. Version 0 is
+// written without header so it stays readable by older deployments.
+func (r *DXRedis) encodeSchemaVersion(valueAsBytes []byte) []byte {
+	if r.SchemaVersion == 0 {
+		return valueAsBytes
+	}
+	return append([]byte{RedisSchemaVersionHeaderMagic, byte(r.SchemaVersion)}, valueAsBytes...)
+}
+
+// decodeSchemaVersion strips the schema version header and reports whether the stored version matches the instance
+// schema_version.
+func (r *DXRedis) decodeSchemaVersion(valueAsBytes []byte) (payload []byte, ok bool) {
+	version := 0
+	payload = valueAsBytes
+	if (len(valueAsBytes) >= 2) && (valueAsBytes[0] == RedisSchemaVersionHeaderMagic) {
+		version = int(valueAsBytes[1])
+		payload = valueAsBytes[2:]
+	}
+	return payload, version == r.SchemaVersion
+}
+
 func (r *DXRedis) Delete(key string) (err error) {
-	_, err = r.Connection.Del(r.Context, key).Result()
+	defer r.invalidateL1(key)
+	defer r.forgetKeyKind(key)
+	err = r.withRetry(func() error {
+		return r.Connection.Del(r.Context, key).Err()
+	})
 	if err != nil {
 		log.Log.Errorf("Error in deleting key Redis %s k/v (%v) %s", r.NameId, err, key)
 		return err
@@ -274,6 +761,274 @@ func (r *DXRedis) Delete(key string) (err error) {
 	return nil
 }
 
+// WaitReplicas blocks until numReplicas replicas acknowledged the previous writes or timeout elapsed, and returns the
+// number of replicas that acknowledged. Reaching the timeout is not an error, like the WAIT command itself.
+func (r *DXRedis) WaitReplicas(numReplicas int, timeout time.Duration) (int, error) {
+	n, err := r.Connection.Wait(r.Context, numReplicas, timeout).Result()
+	if err != nil {
+		log.Log.Errorf("Error in waiting replicas Redis %s (%v) %d", r.NameId, err, numReplicas)
+		return 0, err
+	}
+	return int(n), nil
+}
+
+const redisBlockingPopSliceDuration = 1 * time.Second
+
+// DXRedisPoppedValueError is returned by BRPop when the popped element cannot be decoded. The element is already
+// removed from the list, Payload holds it as popped so the caller can re-queue or dead-letter it.
+type DXRedisPoppedValueError struct {
+	Key     string
+	Payload []byte
+	Err     error
+}
+
+func (e *DXRedisPoppedValueError) Error() string {
+	return fmt.Sprintf("cannot decode value popped from Redis list %s: %s", e.Key, e.Err.Error())
+}
+
+func (e *DXRedisPoppedValueError) Unwrap() error {
+	return e.Err
+}
+
+// BRPop blocks until an element can be popped from the tail of one of keys or timeout elapsed. On timeout it returns an
+// empty key and nil value without error. The wait is done in slices of at most one second so ctx cancellation is
+// honored promptly. An element that cannot be decoded is returned raw in a *DXRedisPoppedValueError, it is not lost.
+func (r *DXRedis) BRPop(ctx context.Context, timeout time.Duration, keys ...string) (key string, value utils.JSON, err error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		err = ctx.Err()
+		if err != nil {
+			return "", nil, err
+		}
+		sliceDuration := time.Until(deadline)
+		if sliceDuration <= 0 {
+			return "", nil, nil
+		}
+		if sliceDuration > redisBlockingPopSliceDuration {
+			sliceDuration = redisBlockingPopSliceDuration
+		}
+		result, err := r.Connection.BRPop(ctx, sliceDuration, keys...).Result()
+		if err != nil {
+			if errors.Is(err, redis.Nil) {
+				continue
+			}
+			if ctx.Err() != nil {
+				return "", nil, ctx.Err()
+			}
+			log.Log.Errorf("Cannot pop from Redis %s list (%s) %v", r.NameId, err.Error(), keys)
+			return "", nil, err
+		}
+		key = result[0]
+		valueAsBytes, ok := r.decodeValue([]byte(result[1]))
+		if !ok {
+			err = log.Log.ErrorAndCreateErrorf("Schema version mismatch in Redis %s list %s", r.NameId, key)
+			return key, nil, &DXRedisPoppedValueError{Key: key, Payload: []byte(result[1]), Err: err}
+		}
+		err = json.Unmarshal(valueAsBytes, &value)
+		if err != nil {
+			log.Log.Errorf("Cannot unmarshall from bytes in Redis %s list (%s) %s/%v", r.NameId, err.Error(), key, valueAsBytes)
+			return key, nil, &DXRedisPoppedValueError{Key: key, Payload: []byte(result[1]), Err: fmt.Errorf(`%w:%s`, ErrRedisValueUnmarshal, err.Error())}
+		}
+		return key, value, nil
+	}
+}
+
+// RunScript runs the script registered under nameId with EVALSHA, falling back to EVAL when the server does not have
+// it cached yet.
+func (r *DXRedis) RunScript(nameId string, keys []string, args ...any) (any, error) {
+	script, ok := r.Owner.Scripts[nameId]
+	if !ok {
+		return nil, errors.New("SCRIPT_NOT_FOUND:" + nameId)
+	}
+	result, err := script.Run(r.Context, r.Connection, keys, args...).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, nil
+		}
+		log.Log.Errorf("Error in running script Redis %s (%v) %s", r.NameId, err, nameId)
+		return nil, err
+	}
+	return result, nil
+}
+
+type DXRedisScanCallback func(key string) (err error)
+
+// Scan iterates keys matching pattern with SCAN and calls callback for every key. ctx is checked between cursor
+// iterations, on cancellation it returns the number of keys processed so far together with ctx.Err().
+func (r *DXRedis) Scan(ctx context.Context, pattern string, count int64, callback DXRedisScanCallback) (processedCount int64, err error) {
+	var cursor uint64
+	for {
+		err = ctx.Err()
+		if err != nil {
+			log.Log.Warnf("Scan in Redis %s cancelled after %d keys (%s) %s", r.NameId, processedCount, err.Error(), pattern)
+			return processedCount, err
+		}
+		var keys []string
+		keys, cursor, err = r.Connection.Scan(ctx, cursor, pattern, count).Result()
+		if err != nil {
+			log.Log.Errorf("Error in scanning keys Redis %s (%v) %s", r.NameId, err, pattern)
+			return processedCount, err
+		}
+		for _, key := range keys {
+			err = callback(key)
+			if err != nil {
+				return processedCount, err
+			}
+			processedCount++
+		}
+		if cursor == 0 {
+			return processedCount, nil
+		}
+	}
+}
+
+// ScanType is Scan limited to keys of dataType ("string", "hash", "list", "set", "zset", "stream") using the SCAN TYPE
+// option. Servers older than Redis 6.0 reject the option, then each batch is filtered client side with pipelined TYPE
+// commands.
+func (r *DXRedis) ScanType(ctx context.Context, pattern string, dataType string, count int64, callback DXRedisScanCallback) (processedCount int64, err error) {
+	serverSideType := true
+	var cursor uint64
+	for {
+		err = ctx.Err()
+		if err != nil {
+			log.Log.Warnf("ScanType in Redis %s cancelled after %d keys (%s) %s", r.NameId, processedCount, err.Error(), pattern)
+			return processedCount, err
+		}
+		var keys []string
+		if serverSideType {
+			keys, cursor, err = r.Connection.ScanType(ctx, cursor, pattern, count, dataType).Result()
+			if (err != nil) && (cursor == 0) && strings.Contains(strings.ToLower(err.Error()), "syntax") {
+				log.Log.Warnf("SCAN TYPE not supported by Redis %s, filtering types client side (%s)", r.NameId, err.Error())
+				serverSideType = false
+				continue
+			}
+		} else {
+			keys, cursor, err = r.Connection.Scan(ctx, cursor, pattern, count).Result()
+			if (err == nil) && (len(keys) > 0) {
+				keys, err = r.filterKeysByType(ctx, keys, dataType)
+			}
+		}
+		if err != nil {
+			log.Log.Errorf("Error in scanning keys Redis %s (%v) %s", r.NameId, err, pattern)
+			return processedCount, err
+		}
+		for _, key := range keys {
+			err = callback(key)
+			if err != nil {
+				return processedCount, err
+			}
+			processedCount++
+		}
+		if cursor == 0 {
+			return processedCount, nil
+		}
+	}
+}
+
+func (r *DXRedis) filterKeysByType(ctx context.Context, keys []string, dataType string) (filteredKeys []string, err error) {
+	pipe := r.Connection.Pipeline()
+	cmds := make([]*redis.StatusCmd, len(keys))
+	for i, key := range keys {
+		cmds[i] = pipe.Type(ctx, key)
+	}
+	_, err = pipe.Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i, cmd := range cmds {
+		if cmd.Val() == dataType {
+			filteredKeys = append(filteredKeys, keys[i])
+		}
+	}
+	return filteredKeys, nil
+}
+
+// DeletePattern deletes every key matching pattern, one SCAN batch at a time. On cancellation it returns the number of
+// keys deleted so far together with ctx.Err().
+func (r *DXRedis) DeletePattern(ctx context.Context, pattern string, count int64) (deletedCount int64, err error) {
+	defer r.purgeL1()
+	var cursor uint64
+	for {
+		err = ctx.Err()
+		if err != nil {
+			log.Log.Warnf("DeletePattern in Redis %s cancelled after %d keys (%s) %s", r.NameId, deletedCount, err.Error(), pattern)
+			return deletedCount, err
+		}
+		var keys []string
+		keys, cursor, err = r.Connection.Scan(ctx, cursor, pattern, count).Result()
+		if err != nil {
+			log.Log.Errorf("Error in scanning keys Redis %s (%v) %s", r.NameId, err, pattern)
+			return deletedCount, err
+		}
+		if len(keys) > 0 {
+			for _, key := range keys {
+				r.forgetKeyKind(key)
+			}
+			n, err := r.Connection.Del(ctx, keys...).Result()
+			if err != nil {
+				log.Log.Errorf("Error in deleting keys Redis %s (%v) %s", r.NameId, err, pattern)
+				return deletedCount, err
+			}
+			deletedCount += n
+		}
+		if cursor == 0 {
+			return deletedCount, nil
+		}
+	}
+}
+
+// GetByPattern returns the values of every key matching pattern, SCANning one batch at a time and fetching each batch
+// with pipelined GETs. Keys that vanished meanwhile are skipped, keys that are not JSON values written by Set are
+// skipped with a warning. On cancellation it returns the values read so far together with ctx.Err().
+func (r *DXRedis) GetByPattern(ctx context.Context, pattern string, count int64) (values map[string]utils.JSON, err error) {
+	values = map[string]utils.JSON{}
+	var cursor uint64
+	for {
+		err = ctx.Err()
+		if err != nil {
+			log.Log.Warnf("GetByPattern in Redis %s cancelled after %d keys (%s) %s", r.NameId, len(values), err.Error(), pattern)
+			return values, err
+		}
+		var keys []string
+		keys, cursor, err = r.Connection.Scan(ctx, cursor, pattern, count).Result()
+		if err != nil {
+			log.Log.Errorf("Error in scanning keys Redis %s (%v) %s", r.NameId, err, pattern)
+			return values, err
+		}
+		if len(keys) > 0 {
+			pipe := r.Connection.Pipeline()
+			cmds := make([]*redis.StringCmd, len(keys))
+			for i, key := range keys {
+				cmds[i] = pipe.Get(ctx, key)
+			}
+			_, _ = pipe.Exec(ctx)
+			for i, cmd := range cmds {
+				valueAsBytes, err := cmd.Bytes()
+				if err != nil {
+					if !errors.Is(err, redis.Nil) {
+						log.Log.Warnf("GetByPattern in Redis %s skipped key (%s) %s", r.NameId, err.Error(), keys[i])
+					}
+					continue
+				}
+				payload, ok := r.decodeValue(valueAsBytes)
+				if !ok {
+					continue
+				}
+				var value utils.JSON
+				err = json.Unmarshal(payload, &value)
+				if err != nil {
+					log.Log.Warnf("GetByPattern in Redis %s skipped non JSON key (%s) %s", r.NameId, err.Error(), keys[i])
+					continue
+				}
+				values[keys[i]] = value
+			}
+		}
+		if cursor == 0 {
+			return values, nil
+		}
+	}
+}
+
 func (r *DXRedis) Disconnect() (err error) {
 	if r.Connected {
 		log.Log.Infof("Disconnecting to Redis %s at %s/%d... start", r.NameId, r.Address, r.DatabaseIndex)
@@ -293,5 +1048,5 @@ func (r *DXRedis) Disconnect() (err error) {
 var Manager DXRedisManager
 
 func init() {
-	Manager = DXRedisManager{Redises: map[string]*DXRedis{}}
+	Manager = DXRedisManager{Redises: map[string]*DXRedis{}, Scripts: map[string]*redis.Script{}}
 }
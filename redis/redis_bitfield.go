@@ -0,0 +1,57 @@
+package redis
+
+import (
+	"github.com/donnyhardyanto/dxlib/log"
+)
+
+const (
+	RedisBitFieldOpGet    = "GET"
+	RedisBitFieldOpSet    = "SET"
+	RedisBitFieldOpIncrBy = "INCRBY"
+)
+
+// DXRedisBitFieldOp is one BITFIELD sub-operation. Type is the field type spec like "u8" or "i16", Offset the bit
+// offset or, prefixed with #, the offset in multiples of the type width, like "#2". Value is ignored by GET.
+type DXRedisBitFieldOp struct {
+	Op     string
+	Type   string
+	Offset string
+	Value  int64
+}
+
+func BitFieldGet(fieldType string, offset string) DXRedisBitFieldOp {
+	return DXRedisBitFieldOp{Op: RedisBitFieldOpGet, Type: fieldType, Offset: offset}
+}
+
+func BitFieldSet(fieldType string, offset string, value int64) DXRedisBitFieldOp {
+	return DXRedisBitFieldOp{Op: RedisBitFieldOpSet, Type: fieldType, Offset: offset, Value: value}
+}
+
+func BitFieldIncrBy(fieldType string, offset string, increment int64) DXRedisBitFieldOp {
+	return DXRedisBitFieldOp{Op: RedisBitFieldOpIncrBy, Type: fieldType, Offset: offset, Value: increment}
+}
+
+// BitField runs ops on key in one BITFIELD command and returns one result per op: the value for GET, the previous
+// value for SET and the new value for INCRBY.
+func (r *DXRedis) BitField(key string, ops ...DXRedisBitFieldOp) (results []int64, err error) {
+	if len(ops) == 0 {
+		return []int64{}, nil
+	}
+	args := []any{}
+	for _, op := range ops {
+		switch op.Op {
+		case RedisBitFieldOpGet:
+			args = append(args, op.Op, op.Type, op.Offset)
+		case RedisBitFieldOpSet, RedisBitFieldOpIncrBy:
+			args = append(args, op.Op, op.Type, op.Offset, op.Value)
+		default:
+			return nil, log.Log.ErrorAndCreateErrorf("Invalid BITFIELD operation in Redis %s: %s", r.NameId, op.Op)
+		}
+	}
+	results, err = r.Connection.BitField(r.Context, key, args...).Result()
+	if err != nil {
+		log.Log.Errorf("Cannot run BITFIELD in Redis %s (%v) %s", r.NameId, err, key)
+		return nil, err
+	}
+	return results, nil
+}
@@ -0,0 +1,106 @@
+package redis
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+
+	"github.com/donnyhardyanto/dxlib/log"
+)
+
+const (
+	RedisCompressionNone   = "none"
+	RedisCompressionAlways = "always"
+	RedisCompressionAuto   = "auto"
+)
+
+// RedisCompressionHeaderMagic marks a gzip compressed value, inside the schema version header when there is one. JSON
+// never starts with this byte, so uncompressed values need no flag and stay readable by older deployments.
+const RedisCompressionHeaderMagic byte = 0x01
+
+const RedisDefaultCompressionThreshold = 1024
+
+// redisCompressionSampleSize is the prefix compressed by auto mode to estimate the ratio of the whole value.
+const redisCompressionSampleSize = 4096
+
+// redisCompressionMaxRatio is the compressed/original ratio of the sample above which auto mode stores the value as is.
+const redisCompressionMaxRatio = 0.9
+
+func gzipBytes(b []byte) ([]byte, error) {
+	var buffer bytes.Buffer
+	w, err := gzip.NewWriterLevel(&buffer, gzip.BestSpeed)
+	if err != nil {
+		return nil, err
+	}
+	_, err = w.Write(b)
+	if err != nil {
+		return nil, err
+	}
+	err = w.Close()
+	if err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// compressValue compresses valueAsBytes according to compression. Values below compression_threshold are never
+// compressed. In auto mode a sample is compressed first and the value is kept as is when it does not shrink enough.
+func (r *DXRedis) compressValue(valueAsBytes []byte) []byte {
+	if (r.Compression != RedisCompressionAlways) && (r.Compression != RedisCompressionAuto) {
+		return valueAsBytes
+	}
+	if len(valueAsBytes) < r.CompressionThreshold {
+		return valueAsBytes
+	}
+	if (r.Compression == RedisCompressionAuto) && (len(valueAsBytes) > redisCompressionSampleSize) {
+		sample, err := gzipBytes(valueAsBytes[:redisCompressionSampleSize])
+		if (err != nil) || (float64(len(sample)) > redisCompressionMaxRatio*redisCompressionSampleSize) {
+			return valueAsBytes
+		}
+	}
+	compressed, err := gzipBytes(valueAsBytes)
+	if err != nil {
+		log.Log.Warnf("Cannot compress value for Redis %s, stored uncompressed (%s)", r.NameId, err.Error())
+		return valueAsBytes
+	}
+	if (r.Compression == RedisCompressionAuto) && (float64(len(compressed)+1) > redisCompressionMaxRatio*float64(len(valueAsBytes))) {
+		return valueAsBytes
+	}
+	return append([]byte{RedisCompressionHeaderMagic}, compressed...)
+}
+
+// decompressValue reverses compressValue, values without the compression flag are returned unchanged whatever the
+// current compression setting, so switching it never makes stored values unreadable.
+func decompressValue(valueAsBytes []byte) ([]byte, error) {
+	if (len(valueAsBytes) == 0) || (valueAsBytes[0] != RedisCompressionHeaderMagic) {
+		return valueAsBytes, nil
+	}
+	rd, err := gzip.NewReader(bytes.NewReader(valueAsBytes[1:]))
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rd.Close()
+	}()
+	return io.ReadAll(rd)
+}
+
+// encodeValue compresses the marshalled value when configured, then adds the schema version header.
+func (r *DXRedis) encodeValue(valueAsBytes []byte) []byte {
+	return r.encodeSchemaVersion(r.compressValue(valueAsBytes))
+}
+
+// decodeValue strips the schema version header and decompresses the payload. A payload that cannot be decompressed is
+// logged and reported like a schema version mismatch, as not ok.
+func (r *DXRedis) decodeValue(valueAsBytes []byte) (payload []byte, ok bool) {
+	payload, ok = r.decodeSchemaVersion(valueAsBytes)
+	if !ok {
+		return nil, false
+	}
+	payload, err := decompressValue(payload)
+	if err != nil {
+		log.Log.Errorf("Cannot decompress value in Redis %s (%s)", r.NameId, err.Error())
+		return nil, false
+	}
+	return payload, true
+}
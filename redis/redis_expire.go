@@ -0,0 +1,78 @@
+package redis
+
+import (
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/donnyhardyanto/dxlib/log"
+)
+
+// redisConditionalExpireScript emulates PEXPIRE NX/GT for servers before Redis 7. A key without TTL counts as an
+// infinite TTL, like Redis 7 does for GT.
+var redisConditionalExpireScript = redis.NewScript(`
+local ttl = redis.call('PTTL', KEYS[1])
+if ttl == -2 then
+	return 0
+end
+if ARGV[2] == 'NX' and ttl ~= -1 then
+	return 0
+end
+if ARGV[2] == 'GT' and (ttl == -1 or tonumber(ARGV[1]) <= ttl) then
+	return 0
+end
+return redis.call('PEXPIRE', KEYS[1], ARGV[1])
+`)
+
+func isUnsupportedExpireOptionError(err error) bool {
+	message := strings.ToLower(err.Error())
+	return strings.Contains(message, "wrong number of arguments") || strings.Contains(message, "syntax error")
+}
+
+// expireWithOption runs PEXPIRE with the NX or GT option, falling back to redisConditionalExpireScript when the server
+// does not know the option.
+func (r *DXRedis) expireWithOption(key string, exp time.Duration, option string) (ok bool, err error) {
+	n, err := r.Connection.Do(r.Context, "pexpire", key, exp.Milliseconds(), option).Int64()
+	if (err != nil) && isUnsupportedExpireOptionError(err) {
+		n, err = redisConditionalExpireScript.Run(r.Context, r.Connection, []string{key}, exp.Milliseconds(), option).Int64()
+	}
+	if err != nil {
+		log.Log.Errorf("Cannot set expiration %s of key in Redis %s (%v) %s", option, r.NameId, err, key)
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// ExpireGT sets the TTL of key to exp only when that is longer than its current TTL, and reports whether it did. A key
+// without TTL never expires and is left untouched.
+func (r *DXRedis) ExpireGT(key string, exp time.Duration) (bool, error) {
+	return r.expireWithOption(key, exp, "GT")
+}
+
+// ExpireNX sets the TTL of key to exp only when it has none, and reports whether it did.
+func (r *DXRedis) ExpireNX(key string, exp time.Duration) (bool, error) {
+	return r.expireWithOption(key, exp, "NX")
+}
+
+// TTLRemaining returns how long key lives on. exists is false for a missing key, hasExpiry false for a key that never
+// expires, ttl is only meaningful when both are true.
+func (r *DXRedis) TTLRemaining(key string) (ttl time.Duration, hasExpiry bool, exists bool, err error) {
+	var n int64
+	err = r.withRetry(func() (err error) {
+		n, err = r.Connection.Do(r.Context, "pttl", key).Int64()
+		return err
+	})
+	if err != nil {
+		log.Log.Errorf("Cannot get TTL of key in Redis %s (%v) %s", r.NameId, err, key)
+		return 0, false, false, err
+	}
+	switch n {
+	case -2:
+		return 0, false, false, nil
+	case -1:
+		return 0, false, true, nil
+	default:
+		return time.Duration(n) * time.Millisecond, true, true, nil
+	}
+}
@@ -0,0 +1,54 @@
+package redis
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/donnyhardyanto/dxlib/log"
+	"github.com/donnyhardyanto/dxlib/utils"
+)
+
+var ErrRedisValueUnmarshal = errors.New(`REDIS_VALUE_UNMARSHAL`)
+
+// GetMulti reads keys with one MGET and returns a slice aligned with keys, nil for misses. Values that cannot be
+// unmarshalled are left nil too and reported in err, one ErrRedisValueUnmarshal per key, joined; values is still
+// returned in that case so the other entries can be used.
+func (r *DXRedis) GetMulti(keys []string) (values []*utils.JSON, err error) {
+	if len(keys) == 0 {
+		return []*utils.JSON{}, nil
+	}
+	var results []any
+	err = r.withRetry(func() (err error) {
+		results, err = r.Connection.MGet(r.Context, keys...).Result()
+		return err
+	})
+	if err != nil {
+		log.Log.Errorf("Cannot get multiple keys to Redis %s k/v (%s) %v", r.NameId, err.Error(), keys)
+		return nil, err
+	}
+	values = make([]*utils.JSON, len(keys))
+	var unmarshalErrs []error
+	for i, result := range results {
+		s, ok := result.(string)
+		if !ok {
+			continue
+		}
+		payload, ok := r.decodeValue([]byte(s))
+		if !ok {
+			continue
+		}
+		var value utils.JSON
+		err = json.Unmarshal(payload, &value)
+		if err != nil {
+			unmarshalErrs = append(unmarshalErrs, fmt.Errorf(`%w:%s (%v)`, ErrRedisValueUnmarshal, keys[i], err))
+			continue
+		}
+		values[i] = &value
+	}
+	err = errors.Join(unmarshalErrs...)
+	if err != nil {
+		log.Log.Errorf("Cannot unmarshall some values in Redis %s k/v (%s)", r.NameId, err.Error())
+	}
+	return values, err
+}
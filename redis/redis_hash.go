@@ -0,0 +1,94 @@
+package redis
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/donnyhardyanto/dxlib/log"
+	"github.com/donnyhardyanto/dxlib/utils"
+)
+
+// HSetAll writes every field of the hash key in a single HSET and sets its expiration in the same pipeline, exp 0 keeps
+// the hash without expiration. Field values are marshalled like Set values, schema version header included.
+func (r *DXRedis) HSetAll(key string, fields map[string]utils.JSON, exp time.Duration) (err error) {
+	if len(fields) == 0 {
+		return nil
+	}
+	values := make([]any, 0, len(fields)*2)
+	for field, value := range fields {
+		valueAsBytes, err := json.Marshal(value)
+		if err != nil {
+			log.Log.Errorf("Cannot marshal hash field for Redis %s (%v) %s/%s", r.NameId, err, key, field)
+			return err
+		}
+		valueAsBytes = r.encodeValue(valueAsBytes)
+		err = r.checkValueSize(key+`/`+field, len(valueAsBytes))
+		if err != nil {
+			return err
+		}
+		values = append(values, field, valueAsBytes)
+	}
+	err = r.withRetry(func() error {
+		_, err := r.Connection.Pipelined(r.Context, func(pipe redis.Pipeliner) error {
+			pipe.HSet(r.Context, key, values...)
+			if exp > 0 {
+				pipe.Expire(r.Context, key, r.jitterTTL(exp))
+			}
+			return nil
+		})
+		return err
+	})
+	if err != nil {
+		log.Log.Errorf("Cannot save hash to Redis %s (%v) %s", r.NameId, err, key)
+		return err
+	}
+	return nil
+}
+
+// HGetAllMulti reads the hashes keys with pipelined HGETALLs and returns their decoded fields keyed by hash key. Missing
+// hashes are omitted, like misses in MGet. Fields are decoded like HSetAll writes them, a field with another schema
+// version is skipped and a field that cannot be unmarshalled fails the call.
+func (r *DXRedis) HGetAllMulti(keys []string) (hashes map[string]map[string]utils.JSON, err error) {
+	hashes = map[string]map[string]utils.JSON{}
+	if len(keys) == 0 {
+		return hashes, nil
+	}
+	var cmds []*redis.StringStringMapCmd
+	err = r.withRetry(func() error {
+		pipe := r.Connection.Pipeline()
+		cmds = make([]*redis.StringStringMapCmd, len(keys))
+		for i, key := range keys {
+			cmds[i] = pipe.HGetAll(r.Context, key)
+		}
+		_, err := pipe.Exec(r.Context)
+		return err
+	})
+	if err != nil {
+		log.Log.Errorf("Cannot get multiple hashes from Redis %s (%v) %v", r.NameId, err, keys)
+		return nil, err
+	}
+	for i, cmd := range cmds {
+		fields := cmd.Val()
+		if len(fields) == 0 {
+			continue
+		}
+		hash := make(map[string]utils.JSON, len(fields))
+		for field, s := range fields {
+			payload, ok := r.decodeValue([]byte(s))
+			if !ok {
+				continue
+			}
+			var value utils.JSON
+			err = json.Unmarshal(payload, &value)
+			if err != nil {
+				log.Log.Errorf("Cannot unmarshall hash field in Redis %s (%v) %s/%s", r.NameId, err, keys[i], field)
+				return nil, err
+			}
+			hash[field] = value
+		}
+		hashes[keys[i]] = hash
+	}
+	return hashes, nil
+}
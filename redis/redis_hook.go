@@ -0,0 +1,92 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// DXRedisHook is called around every command sent through the instance connection. Before may replace the context or
+// abort the command by returning an error. key is the first command argument, empty for commands without one.
+type DXRedisHook interface {
+	Before(ctx context.Context, cmd string, key string) (context.Context, error)
+	After(ctx context.Context, cmd string, key string, err error, duration time.Duration)
+}
+
+// RegisterCommandHook appends hook, hooks are called in registration order.
+func (r *DXRedis) RegisterCommandHook(hook DXRedisHook) {
+	r.CommandHooks = append(r.CommandHooks, hook)
+}
+
+type redisHookStartContextKey struct{}
+
+// redisHookBridge adapts the registered DXRedisHook list to the go-redis hook interface, so every command is covered
+// without each DXRedis method calling the hooks itself.
+type redisHookBridge struct {
+	r *DXRedis
+}
+
+func redisCommandNameAndKey(cmd redis.Cmder) (name string, key string) {
+	name = cmd.Name()
+	args := cmd.Args()
+	if len(args) > 1 {
+		key = fmt.Sprint(args[1])
+	}
+	return name, key
+}
+
+func redisHookError(err error) error {
+	if errors.Is(err, redis.Nil) {
+		return nil
+	}
+	return err
+}
+
+func (h redisHookBridge) before(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	for _, hook := range h.r.CommandHooks {
+		for _, cmd := range cmds {
+			name, key := redisCommandNameAndKey(cmd)
+			var err error
+			ctx, err = hook.Before(ctx, name, key)
+			if err != nil {
+				return ctx, err
+			}
+		}
+	}
+	return context.WithValue(ctx, redisHookStartContextKey{}, time.Now()), nil
+}
+
+func (h redisHookBridge) after(ctx context.Context, cmds []redis.Cmder) {
+	start, ok := ctx.Value(redisHookStartContextKey{}).(time.Time)
+	duration := time.Duration(0)
+	if ok {
+		duration = time.Since(start)
+	}
+	for _, hook := range h.r.CommandHooks {
+		for _, cmd := range cmds {
+			name, key := redisCommandNameAndKey(cmd)
+			hook.After(ctx, name, key, redisHookError(cmd.Err()), duration)
+		}
+	}
+}
+
+func (h redisHookBridge) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	return h.before(ctx, []redis.Cmder{cmd})
+}
+
+func (h redisHookBridge) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	h.after(ctx, []redis.Cmder{cmd})
+	return nil
+}
+
+func (h redisHookBridge) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	return h.before(ctx, cmds)
+}
+
+func (h redisHookBridge) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	h.after(ctx, cmds)
+	return nil
+}
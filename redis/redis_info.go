@@ -0,0 +1,123 @@
+package redis
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/donnyhardyanto/dxlib/log"
+	"github.com/donnyhardyanto/dxlib/utils"
+)
+
+// Info runs INFO on every ring shard and returns the parsed result keyed by shard name, then by lower cased section
+// name. Numeric values are converted to int64 or float64, and comma separated k=v values like the keyspace db lines
+// are parsed into nested objects.
+func (r *DXRedis) Info(sections ...string) (utils.JSON, error) {
+	result := utils.JSON{}
+	mutex := sync.Mutex{}
+	err := r.Connection.ForEachShard(r.Context, func(ctx context.Context, client *redis.Client) error {
+		text, err := client.Info(ctx, sections...).Result()
+		if err != nil {
+			return err
+		}
+		shardName := client.Options().Addr
+		if shardName == r.Address {
+			shardName = RedisRingShardName
+		}
+		mutex.Lock()
+		result[shardName] = ParseInfo(text)
+		mutex.Unlock()
+		return nil
+	})
+	if err != nil {
+		log.Log.Errorf("Cannot get info from Redis %s (%s)", r.NameId, err.Error())
+		return nil, err
+	}
+	return result, nil
+}
+
+func ParseInfo(text string) utils.JSON {
+	result := utils.JSON{}
+	section := utils.JSON{}
+	result[`default`] = section
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == `` {
+			continue
+		}
+		if strings.HasPrefix(line, `#`) {
+			section = utils.JSON{}
+			result[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(line, `#`)))] = section
+			continue
+		}
+		k, v, ok := strings.Cut(line, `:`)
+		if !ok {
+			continue
+		}
+		section[k] = parseInfoValue(v)
+	}
+	if len(result[`default`].(utils.JSON)) == 0 {
+		delete(result, `default`)
+	}
+	return result
+}
+
+func parseInfoValue(v string) any {
+	if strings.Contains(v, `=`) {
+		m := utils.JSON{}
+		for _, part := range strings.Split(v, `,`) {
+			k, pv, ok := strings.Cut(part, `=`)
+			if !ok {
+				return v
+			}
+			m[k] = parseInfoValue(pv)
+		}
+		return m
+	}
+	i, err := strconv.ParseInt(v, 10, 64)
+	if err == nil {
+		return i
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err == nil {
+		return f
+	}
+	return v
+}
+
+// PoolStats returns the client side connection pool counters summed over every Ring shard, or nil before Connect.
+func (r *DXRedis) PoolStats() *redis.PoolStats {
+	if r.Connection == nil {
+		return nil
+	}
+	return r.Connection.PoolStats()
+}
+
+// PingLatency measures the round trip time of a PING on every Ring shard and returns the slowest one, so a single slow
+// shard is never hidden by an average.
+func (r *DXRedis) PingLatency() (latency time.Duration, err error) {
+	mutex := sync.Mutex{}
+	err = r.Connection.ForEachShard(r.Context, func(ctx context.Context, client *redis.Client) error {
+		start := time.Now()
+		err := client.Ping(ctx).Err()
+		if err != nil {
+			return err
+		}
+		elapsed := time.Since(start)
+		mutex.Lock()
+		if elapsed > latency {
+			latency = elapsed
+		}
+		mutex.Unlock()
+		return nil
+	})
+	if err != nil {
+		log.Log.Errorf("Cannot ping Redis %s (%s)", r.NameId, err.Error())
+		return 0, err
+	}
+	return latency, nil
+}
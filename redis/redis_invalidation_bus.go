@@ -0,0 +1,66 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/donnyhardyanto/dxlib/log"
+)
+
+// redisInvalidationEvents are the keyevent notifications that change or remove a string value.
+var redisInvalidationEvents = []string{"set", "del", "expired", "evicted", "rename_from", "rename_to"}
+
+type dxRedisInvalidationWatch struct {
+	prefix       string
+	onInvalidate func(key string)
+}
+
+// DXRedisInvalidationBus turns the keyspace events of a DXRedis database into local cache invalidations, so in-process
+// caches like the L1 cache stay coherent with writes made by any instance:
+//
+//	bus.Watch("session:", r.L1Cache.Delete)
+//
+// It is eventually consistent: a local cache still serves the old value until the event arrives, usually within
+// milliseconds, and events published while the bus is resubscribing after a connection loss are missed, so cached
+// entries must keep a short TTL as upper bound of staleness.
+type DXRedisInvalidationBus struct {
+	Redis   *DXRedis
+	watches []dxRedisInvalidationWatch
+	mutex   sync.RWMutex
+}
+
+func NewDXRedisInvalidationBus(r *DXRedis) *DXRedisInvalidationBus {
+	return &DXRedisInvalidationBus{Redis: r}
+}
+
+// Watch registers onInvalidate for every changed key starting with prefix, an empty prefix matches every key.
+func (b *DXRedisInvalidationBus) Watch(prefix string, onInvalidate func(key string)) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.watches = append(b.watches, dxRedisInvalidationWatch{prefix: prefix, onInvalidate: onInvalidate})
+}
+
+// Run delivers invalidations until ctx is done, resubscribing after connection losses. The server must publish
+// generic, string, expired and evicted keyevent notifications, Run tries to enable them and only warns when it cannot.
+func (b *DXRedisInvalidationBus) Run(ctx context.Context) (err error) {
+	r := b.Redis
+	err = r.EnsureKeyspaceEvents(`Eg$xe`)
+	if err != nil {
+		log.Log.Warnf("Cannot enable keyspace events in Redis %s, make sure notify-keyspace-events contains Eg$xe (%s)", r.NameId, err.Error())
+	}
+	channels := make([]string, len(redisInvalidationEvents))
+	for i, event := range redisInvalidationEvents {
+		channels[i] = fmt.Sprintf("__keyevent@%d__:%s", r.DatabaseIndex, event)
+	}
+	return r.Subscribe(ctx, channels, func(channel string, key string) {
+		b.mutex.RLock()
+		defer b.mutex.RUnlock()
+		for _, watch := range b.watches {
+			if strings.HasPrefix(key, watch.prefix) {
+				watch.onInvalidate(key)
+			}
+		}
+	})
+}
@@ -0,0 +1,105 @@
+package redis
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/donnyhardyanto/dxlib/log"
+	"github.com/donnyhardyanto/dxlib/utils"
+)
+
+var ErrRedisJSONModuleNotAvailable = errors.New(`REDIS_JSON_MODULE_NOT_AVAILABLE:RedisJSON module not available`)
+
+// redisJSONError maps the unknown command error of a server without RedisJSON to ErrRedisJSONModuleNotAvailable.
+func redisJSONError(err error) error {
+	if strings.Contains(strings.ToLower(err.Error()), "unknown command") {
+		return ErrRedisJSONModuleNotAvailable
+	}
+	return err
+}
+
+// JSONSet writes value at path of the RedisJSON document key with JSON.SET, path "$" replaces the whole document. Values
+// written this way are not readable with Get, they are not plain strings.
+func (r *DXRedis) JSONSet(key, path string, value utils.JSON) (err error) {
+	defer r.invalidateL1(key)
+	valueAsBytes, err := json.Marshal(value)
+	if err != nil {
+		log.Log.Errorf("Cannot marshal JSON for Redis %s JSON.SET (%v) %s/%s", r.NameId, err, key, path)
+		return err
+	}
+	err = r.Connection.Do(r.Context, "JSON.SET", key, path, string(valueAsBytes)).Err()
+	if err != nil {
+		err = redisJSONError(err)
+		log.Log.Errorf("Cannot JSON.SET in Redis %s (%v) %s/%s", r.NameId, err, key, path)
+		return err
+	}
+	return nil
+}
+
+// JSONGet reads the object at path of the RedisJSON document key with JSON.GET, or nil, nil when the key does not
+// exist or a JSONPath ("$...") matches nothing. A JSONPath result matching a single object is unwrapped from its array.
+func (r *DXRedis) JSONGet(key, path string) (value utils.JSON, err error) {
+	s, err := r.Connection.Do(r.Context, "JSON.GET", key, path).Text()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, nil
+		}
+		err = redisJSONError(err)
+		log.Log.Errorf("Cannot JSON.GET in Redis %s (%v) %s/%s", r.NameId, err, key, path)
+		return nil, err
+	}
+	var v any
+	err = json.Unmarshal([]byte(s), &v)
+	if err != nil {
+		log.Log.Errorf("Cannot unmarshall JSON.GET result in Redis %s (%v) %s/%s", r.NameId, err, key, path)
+		return nil, err
+	}
+	if a, ok := v.([]any); ok {
+		if len(a) == 0 {
+			return nil, nil
+		}
+		if len(a) == 1 {
+			v = a[0]
+		}
+	}
+	value, ok := v.(map[string]any)
+	if !ok {
+		err = log.Log.ErrorAndCreateErrorf("JSON.GET result in Redis %s is not an object %s/%s", r.NameId, key, path)
+		return nil, err
+	}
+	return value, nil
+}
+
+// GetField returns the object at the dotted fieldPath ("a.b.c") inside the value of key, or nil, nil when the key or
+// the path does not exist. Values written by Set are fetched whole and the path is walked client side, it only saves
+// caller code. Keys holding a RedisJSON document, written by JSONSet, are read with a server side JSON.GET of the path so
+// only the field is transferred.
+func (r *DXRedis) GetField(key, fieldPath string) (value utils.JSON, err error) {
+	document, err := r.Get(key)
+	if (err != nil) && strings.Contains(err.Error(), "WRONGTYPE") {
+		return r.JSONGet(key, `$.`+fieldPath)
+	}
+	if (err != nil) || (document == nil) {
+		return nil, err
+	}
+	var current any = document
+	for _, name := range strings.Split(fieldPath, `.`) {
+		m, ok := current.(map[string]any)
+		if !ok {
+			return nil, nil
+		}
+		current, ok = m[name]
+		if !ok {
+			return nil, nil
+		}
+	}
+	value, ok := current.(map[string]any)
+	if !ok {
+		err = log.Log.ErrorAndCreateErrorf("Field %s of Redis %s value is not an object %s", fieldPath, r.NameId, key)
+		return nil, err
+	}
+	return value, nil
+}
@@ -0,0 +1,120 @@
+package redis
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/donnyhardyanto/dxlib/log"
+)
+
+var ErrRedisKeyKindMismatch = errors.New(`REDIS_KEY_KIND_MISMATCH`)
+
+const (
+	RedisKeyKindJSON    = "json"
+	RedisKeyKindCounter = "counter"
+)
+
+// DXRedisKeyKinds remembers the kind of every key this process wrote, for the strict_types guard. It only knows about
+// keys written through this instance since the process started.
+type DXRedisKeyKinds struct {
+	kinds map[string]string
+	mutex sync.Mutex
+}
+
+func NewDXRedisKeyKinds() *DXRedisKeyKinds {
+	return &DXRedisKeyKinds{kinds: map[string]string{}}
+}
+
+// checkKeyKind fails with ErrRedisKeyKindMismatch when strict_types is enabled and key was written with another kind.
+// Writes call recordKeyKind once the value is actually written.
+func (r *DXRedis) checkKeyKind(key string, kind string) (err error) {
+	if r.KeyKinds == nil {
+		return nil
+	}
+	r.KeyKinds.mutex.Lock()
+	defer r.KeyKinds.mutex.Unlock()
+	current, ok := r.KeyKinds.kinds[key]
+	if ok && (current != kind) {
+		err = fmt.Errorf(`%w:key %s holds a %s value, cannot use it as %s`, ErrRedisKeyKindMismatch, key, current, kind)
+		log.Log.Errorf("Key kind mismatch in Redis %s (%s)", r.NameId, err.Error())
+		return err
+	}
+	return nil
+}
+
+func (r *DXRedis) recordKeyKind(key string, kind string) {
+	if r.KeyKinds == nil {
+		return
+	}
+	r.KeyKinds.mutex.Lock()
+	r.KeyKinds.kinds[key] = kind
+	r.KeyKinds.mutex.Unlock()
+}
+
+func (r *DXRedis) forgetKeyKind(key string) {
+	if r.KeyKinds == nil {
+		return
+	}
+	r.KeyKinds.mutex.Lock()
+	delete(r.KeyKinds.kinds, key)
+	r.KeyKinds.mutex.Unlock()
+}
+
+// Increment adds delta to the integer counter key, creating it at 0, and returns the new value. Counters are raw
+// integers, not JSON, they cannot be read with Get.
+func (r *DXRedis) Increment(key string, delta int64) (value int64, err error) {
+	err = r.checkKeyKind(key, RedisKeyKindCounter)
+	if err != nil {
+		return 0, err
+	}
+	value, err = r.Connection.IncrBy(r.Context, key, delta).Result()
+	if err != nil {
+		log.Log.Errorf("Cannot increment counter in Redis %s (%v) %s", r.NameId, err, key)
+		return 0, err
+	}
+	r.recordKeyKind(key, RedisKeyKindCounter)
+	return value, nil
+}
+
+var redisIncrementWithExpiryScript = redis.NewScript(`
+local value = redis.call('INCRBY', KEYS[1], ARGV[1])
+if (value == tonumber(ARGV[1])) and (tonumber(ARGV[2]) > 0) then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return value
+`)
+
+// IncrementWithExpiry is Increment that also sets exp on the counter when this increment created it, atomically in a
+// Lua script, so the expiration of a fixed window counter is never reset by later increments. A counter created by a
+// plain Increment, or with a delta of 0, never gets an expiration from it.
+func (r *DXRedis) IncrementWithExpiry(key string, delta int64, exp time.Duration) (value int64, err error) {
+	err = r.checkKeyKind(key, RedisKeyKindCounter)
+	if err != nil {
+		return 0, err
+	}
+	value, err = redisIncrementWithExpiryScript.Run(r.Context, r.Connection, []string{key}, delta, exp.Milliseconds()).Int64()
+	if err != nil {
+		log.Log.Errorf("Cannot increment counter with expiry in Redis %s (%v) %s", r.NameId, err, key)
+		return 0, err
+	}
+	r.recordKeyKind(key, RedisKeyKindCounter)
+	return value, nil
+}
+
+// moveKeyKind transfers the recorded kind of oldKey to newKey after a rename.
+func (r *DXRedis) moveKeyKind(oldKey string, newKey string) {
+	if r.KeyKinds == nil {
+		return
+	}
+	r.KeyKinds.mutex.Lock()
+	kind, ok := r.KeyKinds.kinds[oldKey]
+	delete(r.KeyKinds.kinds, oldKey)
+	if ok {
+		r.KeyKinds.kinds[newKey] = kind
+	}
+	r.KeyKinds.mutex.Unlock()
+}
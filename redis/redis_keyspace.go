@@ -0,0 +1,113 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/donnyhardyanto/dxlib/log"
+	"github.com/donnyhardyanto/dxlib/utils"
+)
+
+type DXRedisKeyEventHandler func(key string)
+
+// EnsureKeyspaceEvents makes sure the server publishes the notify-keyspace-events classes in flags, adding the missing
+// ones with CONFIG SET. Managed Redis services often forbid CONFIG, then notifications must be enabled on the server
+// side and the error is returned for the caller to log.
+func (r *DXRedis) EnsureKeyspaceEvents(flags string) (err error) {
+	result, err := r.Connection.ConfigGet(r.Context, "notify-keyspace-events").Result()
+	if err != nil {
+		return err
+	}
+	current := ``
+	if len(result) == 2 {
+		current, _ = result[1].(string)
+	}
+	missing := ``
+	for _, flag := range flags {
+		if strings.ContainsRune(current, flag) {
+			continue
+		}
+		// A is the alias of every event class except key miss and new key
+		if strings.ContainsRune(current, 'A') && strings.ContainsRune("g$lshzxetd", flag) {
+			continue
+		}
+		missing = missing + string(flag)
+	}
+	if missing == `` {
+		return nil
+	}
+	return r.Connection.ConfigSet(r.Context, "notify-keyspace-events", current+missing).Err()
+}
+
+// SubscribeKeyExpired calls handler for every expired key in the instance database matching pattern, until ctx is done.
+// pattern uses path.Match syntax, where * does not match a "/". The server must have keyevent expired notifications
+// enabled (notify-keyspace-events containing E and x), SubscribeKeyExpired tries to enable them and only warns when it
+// cannot.
+func (r *DXRedis) SubscribeKeyExpired(ctx context.Context, pattern string, handler DXRedisKeyEventHandler) (err error) {
+	_, err = path.Match(pattern, ``)
+	if err != nil {
+		return err
+	}
+	err = r.EnsureKeyspaceEvents(`Ex`)
+	if err != nil {
+		log.Log.Warnf("Cannot enable expired keyspace events in Redis %s, make sure notify-keyspace-events contains Ex (%s)", r.NameId, err.Error())
+	}
+	channel := fmt.Sprintf("__keyevent@%d__:expired", r.DatabaseIndex)
+	return r.Subscribe(ctx, []string{channel}, func(channel string, payload string) {
+		matched, _ := path.Match(pattern, payload)
+		if matched {
+			handler(payload)
+		}
+	})
+}
+
+const RedisWaitForKeyDefaultPollInterval = 100 * time.Millisecond
+
+// WaitForKey blocks until key exists and returns its value, or until ctx is done. It subscribes to the keyspace
+// notifications of key to wake up as soon as it is written and still polls every pollInterval, so it also works,
+// only slower, when notify-keyspace-events cannot be enabled. A pollInterval of 0 or less polls every
+// RedisWaitForKeyDefaultPollInterval.
+func (r *DXRedis) WaitForKey(ctx context.Context, key string, pollInterval time.Duration) (value utils.JSON, err error) {
+	if pollInterval <= 0 {
+		pollInterval = RedisWaitForKeyDefaultPollInterval
+	}
+	var messages <-chan *redis.Message
+	err = r.EnsureKeyspaceEvents(`K$`)
+	if err != nil {
+		log.Log.Warnf("Cannot enable keyspace events in Redis %s, WaitForKey falls back to polling (%s) %s", r.NameId, err.Error(), key)
+	} else {
+		channel := fmt.Sprintf("__keyspace@%d__:%s", r.DatabaseIndex, key)
+		pubSub := r.Connection.Subscribe(ctx, channel)
+		defer func() {
+			_ = pubSub.Close()
+		}()
+		_, err = pubSub.Receive(ctx)
+		if err != nil {
+			log.Log.Warnf("Cannot subscribe to Redis %s keyspace events, WaitForKey falls back to polling (%s) %s", r.NameId, err.Error(), key)
+		} else {
+			messages = pubSub.Channel()
+		}
+	}
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		value, err = r.Get(key)
+		if err != nil {
+			return nil, err
+		}
+		if value != nil {
+			return value, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-messages:
+		case <-ticker.C:
+		}
+	}
+}
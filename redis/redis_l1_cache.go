@@ -0,0 +1,132 @@
+package redis
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/donnyhardyanto/dxlib/log"
+)
+
+// DXRedisL1Cache is a small in-process LRU with a short TTL in front of Redis. It holds the marshalled value so every
+// hit returns a fresh copy.
+type DXRedisL1Cache struct {
+	Size  int
+	TTL   time.Duration
+	items map[string]*list.Element
+	order *list.List
+	mutex sync.Mutex
+}
+
+type dxRedisL1CacheEntry struct {
+	key       string
+	value     []byte
+	expiresAt time.Time
+}
+
+func NewDXRedisL1Cache(size int, ttl time.Duration) *DXRedisL1Cache {
+	return &DXRedisL1Cache{
+		Size:  size,
+		TTL:   ttl,
+		items: map[string]*list.Element{},
+		order: list.New(),
+	}
+}
+
+func (c *DXRedisL1Cache) Get(key string) (value []byte, ok bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	element, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	entry := element.Value.(*dxRedisL1CacheEntry)
+	if time.Now().After(entry.expiresAt) {
+		c.order.Remove(element)
+		delete(c.items, key)
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	return entry.value, true
+}
+
+func (c *DXRedisL1Cache) Set(key string, value []byte) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	expiresAt := time.Now().Add(c.TTL)
+	element, ok := c.items[key]
+	if ok {
+		entry := element.Value.(*dxRedisL1CacheEntry)
+		entry.value = value
+		entry.expiresAt = expiresAt
+		c.order.MoveToFront(element)
+		return
+	}
+	c.items[key] = c.order.PushFront(&dxRedisL1CacheEntry{key: key, value: value, expiresAt: expiresAt})
+	for c.order.Len() > c.Size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*dxRedisL1CacheEntry).key)
+	}
+}
+
+func (c *DXRedisL1Cache) Delete(key string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	element, ok := c.items[key]
+	if !ok {
+		return
+	}
+	c.order.Remove(element)
+	delete(c.items, key)
+}
+
+func (c *DXRedisL1Cache) Purge() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.items = map[string]*list.Element{}
+	c.order.Init()
+}
+
+// invalidateL1 drops key from the local L1 cache and, when l1_invalidation_channel is configured, tells the other
+// instances to drop it too.
+func (r *DXRedis) invalidateL1(key string) {
+	if r.L1Cache == nil {
+		return
+	}
+	r.L1Cache.Delete(key)
+	if r.L1InvalidationChannel == "" {
+		return
+	}
+	err := r.Connection.Publish(r.Context, r.L1InvalidationChannel, key).Err()
+	if err != nil {
+		log.Log.Warnf("Cannot publish L1 invalidation in Redis %s (%s) %s", r.NameId, err.Error(), key)
+	}
+}
+
+func (r *DXRedis) purgeL1() {
+	if r.L1Cache == nil {
+		return
+	}
+	r.L1Cache.Purge()
+	if r.L1InvalidationChannel == "" {
+		return
+	}
+	err := r.Connection.Publish(r.Context, r.L1InvalidationChannel, "").Err()
+	if err != nil {
+		log.Log.Warnf("Cannot publish L1 purge in Redis %s (%s)", r.NameId, err.Error())
+	}
+}
+
+// runL1InvalidationSubscriber drops the L1 entries other instances invalidated, an empty key purges everything. It runs
+// until the instance context is done and survives Redis restarts, invalidations sent while resubscribing are missed and
+// only expire with l1_ttl.
+func (r *DXRedis) runL1InvalidationSubscriber() {
+	_ = r.Subscribe(r.Context, []string{r.L1InvalidationChannel}, func(channel string, payload string) {
+		if payload == "" {
+			r.L1Cache.Purge()
+			return
+		}
+		r.L1Cache.Delete(payload)
+	})
+}
@@ -0,0 +1,48 @@
+package redis
+
+import (
+	"encoding/json"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/donnyhardyanto/dxlib/log"
+	"github.com/donnyhardyanto/dxlib/utils"
+)
+
+// LPushCapped pushes values to the head of the list key, the last value ending first, and trims the list to its maxLen
+// newest entries in one MULTI/EXEC, then returns the resulting length. Values are encoded like Set, BRPop reads them.
+// It is not retried, a retried push could duplicate entries.
+func (r *DXRedis) LPushCapped(key string, maxLen int64, values ...utils.JSON) (length int64, err error) {
+	if maxLen <= 0 {
+		return 0, log.Log.ErrorAndCreateErrorf("Invalid list max length in Redis %s: %d %s", r.NameId, maxLen, key)
+	}
+	if len(values) == 0 {
+		return r.Connection.LLen(r.Context, key).Result()
+	}
+	args := make([]any, len(values))
+	for i, value := range values {
+		valueAsBytes, err := json.Marshal(value)
+		if err != nil {
+			log.Log.Errorf("Cannot marshal list value for Redis %s (%v) %s/%v", r.NameId, err, key, value)
+			return 0, err
+		}
+		valueAsBytes = r.encodeValue(valueAsBytes)
+		err = r.checkValueSize(key, len(valueAsBytes))
+		if err != nil {
+			return 0, err
+		}
+		args[i] = valueAsBytes
+	}
+	var lenCmd *redis.IntCmd
+	_, err = r.Connection.TxPipelined(r.Context, func(pipe redis.Pipeliner) error {
+		pipe.LPush(r.Context, key, args...)
+		pipe.LTrim(r.Context, key, 0, maxLen-1)
+		lenCmd = pipe.LLen(r.Context, key)
+		return nil
+	})
+	if err != nil {
+		log.Log.Errorf("Cannot push to capped list in Redis %s (%v) %s", r.NameId, err, key)
+		return 0, err
+	}
+	return lenCmd.Val(), nil
+}
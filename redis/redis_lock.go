@@ -0,0 +1,75 @@
+package redis
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/donnyhardyanto/dxlib/log"
+)
+
+var redisAcquireLocksScript = redis.NewScript(`
+for i = 1, #KEYS do
+	if redis.call('EXISTS', KEYS[i]) == 1 then
+		return 0
+	end
+end
+for i = 1, #KEYS do
+	redis.call('SET', KEYS[i], ARGV[1], 'PX', ARGV[2])
+end
+return 1
+`)
+
+var redisReleaseLocksScript = redis.NewScript(`
+local n = 0
+for i = 1, #KEYS do
+	if redis.call('GET', KEYS[i]) == ARGV[1] then
+		n = n + redis.call('DEL', KEYS[i])
+	end
+end
+return n
+`)
+
+// AcquireLocks takes the locks keys all at once or none of them, in one Lua script, each expiring after ttl. The keys
+// are sorted first so every caller works on them in the same order. When any of them is already held acquired is false
+// and release is nil. release only deletes the locks still owned by token, it is safe to call more than once. A script
+// runs on a single Ring shard, with more shards the keys must share a hash tag.
+func (r *DXRedis) AcquireLocks(keys []string, ttl time.Duration) (token string, acquired bool, release func() error, err error) {
+	if len(keys) == 0 {
+		return "", false, nil, log.Log.ErrorAndCreateErrorf("AcquireLocks in Redis %s needs at least one key", r.NameId)
+	}
+	if ttl < time.Millisecond {
+		return "", false, nil, log.Log.ErrorAndCreateErrorf("AcquireLocks in Redis %s needs a ttl of at least 1ms, got %v", r.NameId, ttl)
+	}
+	sortedKeys := append([]string{}, keys...)
+	sort.Strings(sortedKeys)
+	tokenAsBytes := make([]byte, 16)
+	_, err = rand.Read(tokenAsBytes)
+	if err != nil {
+		return "", false, nil, err
+	}
+	token = hex.EncodeToString(tokenAsBytes)
+	n, err := redisAcquireLocksScript.Run(r.Context, r.Connection, sortedKeys, token, ttl.Milliseconds()).Int64()
+	if err != nil {
+		log.Log.Errorf("Cannot acquire locks in Redis %s (%v) %v", r.NameId, err, sortedKeys)
+		return "", false, nil, err
+	}
+	if n != 1 {
+		return "", false, nil, nil
+	}
+	var releaseOnce sync.Once
+	release = func() (err error) {
+		releaseOnce.Do(func() {
+			err = redisReleaseLocksScript.Run(r.Context, r.Connection, sortedKeys, token).Err()
+			if err != nil {
+				log.Log.Errorf("Cannot release locks in Redis %s (%v) %v", r.NameId, err, sortedKeys)
+			}
+		})
+		return err
+	}
+	return token, true, release, nil
+}
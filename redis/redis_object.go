@@ -0,0 +1,74 @@
+package redis
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/donnyhardyanto/dxlib/log"
+)
+
+var ErrRedisKeyNotFound = errors.New(`REDIS_KEY_NOT_FOUND`)
+
+// ObjectIdleTime returns how long key has not been read or written, from OBJECT IDLETIME. It is only meaningful when the
+// server maxmemory-policy is not an LFU one, Redis then rejects the command.
+func (r *DXRedis) ObjectIdleTime(key string) (idleTime time.Duration, err error) {
+	idleTime, err = r.Connection.ObjectIdleTime(r.Context, key).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return 0, fmt.Errorf(`%w:%s`, ErrRedisKeyNotFound, key)
+		}
+		log.Log.Errorf("Cannot get object idle time in Redis %s (%v) %s", r.NameId, err, key)
+		return 0, err
+	}
+	return idleTime, nil
+}
+
+// ObjectEncoding returns the internal encoding Redis uses to store key, like "listpack", "hashtable" or "embstr", from
+// OBJECT ENCODING.
+func (r *DXRedis) ObjectEncoding(key string) (encoding string, err error) {
+	encoding, err = r.Connection.ObjectEncoding(r.Context, key).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return "", fmt.Errorf(`%w:%s`, ErrRedisKeyNotFound, key)
+		}
+		log.Log.Errorf("Cannot get object encoding in Redis %s (%v) %s", r.NameId, err, key)
+		return "", err
+	}
+	return encoding, nil
+}
+
+// Touch updates the last access time of keys without reading their values and returns how many of them exist.
+func (r *DXRedis) Touch(keys ...string) (touchedCount int64, err error) {
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	touchedCount, err = r.Connection.Touch(r.Context, keys...).Result()
+	if err != nil {
+		log.Log.Errorf("Cannot touch keys in Redis %s (%v) %v", r.NameId, err, keys)
+		return 0, err
+	}
+	return touchedCount, nil
+}
+
+// RenameNX renames oldKey to newKey only when newKey does not exist, and reports whether it did. A missing oldKey is
+// ErrRedisKeyNotFound, not a false return.
+func (r *DXRedis) RenameNX(oldKey string, newKey string) (renamed bool, err error) {
+	renamed, err = r.Connection.RenameNX(r.Context, oldKey, newKey).Result()
+	if err != nil {
+		if strings.Contains(err.Error(), "no such key") {
+			return false, fmt.Errorf(`%w:%s`, ErrRedisKeyNotFound, oldKey)
+		}
+		log.Log.Errorf("Cannot rename key in Redis %s (%v) %s to %s", r.NameId, err, oldKey, newKey)
+		return false, err
+	}
+	if renamed {
+		r.invalidateL1(oldKey)
+		r.invalidateL1(newKey)
+		r.moveKeyKind(oldKey, newKey)
+	}
+	return renamed, nil
+}
@@ -0,0 +1,62 @@
+package redis
+
+import (
+	"context"
+	"time"
+
+	"github.com/donnyhardyanto/dxlib/log"
+)
+
+const (
+	redisResubscribeDelayMin = 100 * time.Millisecond
+	redisResubscribeDelayMax = 30 * time.Second
+)
+
+// DXRedisMessageHandler receives the channel and payload of every message. Pub/sub is fire-and-forget, messages
+// published while the subscription is being re-established are missed.
+type DXRedisMessageHandler func(channel string, payload string)
+
+// Subscribe calls handler for every message published on channels until ctx is done. When the connection drops, the
+// subscription is re-established with backoff and every reconnection is logged. It returns nil once ctx is done.
+func (r *DXRedis) Subscribe(ctx context.Context, channels []string, handler DXRedisMessageHandler) (err error) {
+	delay := redisResubscribeDelayMin
+	for {
+		err = r.receiveMessages(ctx, channels, handler, func() {
+			delay = redisResubscribeDelayMin
+		})
+		if ctx.Err() != nil {
+			return nil
+		}
+		log.Log.Warnf("Subscription to Redis %s channels %v lost, resubscribing in %v (%s)", r.NameId, channels, delay, err.Error())
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(delay):
+		}
+		delay = delay * 2
+		if delay > redisResubscribeDelayMax {
+			delay = redisResubscribeDelayMax
+		}
+	}
+}
+
+// receiveMessages runs one subscription until it fails, calling onSubscribed once the server confirmed it.
+func (r *DXRedis) receiveMessages(ctx context.Context, channels []string, handler DXRedisMessageHandler, onSubscribed func()) (err error) {
+	pubSub := r.Connection.Subscribe(ctx, channels...)
+	defer func() {
+		_ = pubSub.Close()
+	}()
+	_, err = pubSub.Receive(ctx)
+	if err != nil {
+		return err
+	}
+	onSubscribed()
+	log.Log.Infof("Subscribed to Redis %s channels %v", r.NameId, channels)
+	for {
+		message, err := pubSub.ReceiveMessage(ctx)
+		if err != nil {
+			return err
+		}
+		handler(message.Channel, message.Payload)
+	}
+}
@@ -0,0 +1,49 @@
+package redis
+
+import (
+	"math/rand"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/donnyhardyanto/dxlib/log"
+)
+
+// The script takes the clock from the server TIME command, so every client shares one clock source regardless of
+// their own clock skew. Timestamps are in microseconds.
+var redisSlidingWindowScript = redis.NewScript(`
+if redis.replicate_commands then
+	redis.replicate_commands()
+end
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+local window = tonumber(ARGV[1])
+local limit = tonumber(ARGV[2])
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now - window)
+local count = redis.call('ZCARD', KEYS[1])
+if count < limit then
+	redis.call('ZADD', KEYS[1], now, now .. '-' .. ARGV[3])
+	redis.call('PEXPIRE', KEYS[1], math.ceil(window / 1000))
+	return {1, count + 1}
+end
+return {0, count}
+`)
+
+// SlidingWindowAllow records a request in the sliding window log of key and reports whether it is allowed, that is
+// fewer than limit requests were recorded in the last window. count is the number of requests in the window including
+// this one when allowed. Rejected requests are not recorded.
+func (r *DXRedis) SlidingWindowAllow(key string, limit int, window time.Duration) (allowed bool, count int, err error) {
+	result, err := redisSlidingWindowScript.Run(r.Context, r.Connection, []string{key}, window.Microseconds(), limit, strconv.FormatInt(rand.Int63(), 36)).Slice()
+	if err != nil {
+		log.Log.Errorf("Cannot check sliding window rate limit in Redis %s (%v) %s", r.NameId, err, key)
+		return false, 0, err
+	}
+	if len(result) != 2 {
+		err = log.Log.ErrorAndCreateErrorf("Unexpected sliding window rate limit result in Redis %s (%v) %s", r.NameId, result, key)
+		return false, 0, err
+	}
+	allowedAsInt64, _ := result[0].(int64)
+	countAsInt64, _ := result[1].(int64)
+	return allowedAsInt64 == 1, int(countAsInt64), nil
+}
@@ -0,0 +1,99 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/donnyhardyanto/dxlib/log"
+)
+
+const (
+	RedisDefaultMaxRetries           = 0
+	RedisDefaultRetryBudgetRatio     = 0.1
+	RedisDefaultRetryBudgetMaxTokens = 10
+	redisRetryBackoff                = 50 * time.Millisecond
+)
+
+// DXRedisRetryBudget is a token bucket shared by all operations of one instance. Every request deposits Ratio token
+// and every retry withdraws one, so retries are capped to Ratio of the requests, with MaxTokens allowing short bursts.
+type DXRedisRetryBudget struct {
+	Ratio     float64
+	MaxTokens float64
+	tokens    float64
+	mutex     sync.Mutex
+}
+
+func NewDXRedisRetryBudget(ratio float64, maxTokens float64) *DXRedisRetryBudget {
+	return &DXRedisRetryBudget{
+		Ratio:     ratio,
+		MaxTokens: maxTokens,
+		tokens:    maxTokens,
+	}
+}
+
+func (b *DXRedisRetryBudget) OnRequest() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.tokens += b.Ratio
+	if b.tokens > b.MaxTokens {
+		b.tokens = b.MaxTokens
+	}
+}
+
+func (b *DXRedisRetryBudget) TryWithdraw() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// isRedisRetryableError reports whether err means the command never reached the server or was refused before being
+// processed: network errors, timeouts, a closed connection, and the LOADING, TRYAGAIN, CLUSTERDOWN and MASTERDOWN
+// replies. Any other reply was applied or rejected by the server and is never retried.
+func isRedisRetryableError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, redis.Nil) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var redisErr redis.Error
+	if errors.As(err, &redisErr) {
+		message := redisErr.Error()
+		return strings.HasPrefix(message, "LOADING ") || strings.HasPrefix(message, "TRYAGAIN ") ||
+			strings.HasPrefix(message, "CLUSTERDOWN ") || strings.HasPrefix(message, "MASTERDOWN ")
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, net.ErrClosed) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// withRetry runs operation and retries it up to MaxRetries times on backend errors, as long as the retry budget allows.
+// When the budget is exhausted the error is returned right away.
+func (r *DXRedis) withRetry(operation func() error) (err error) {
+	if r.RetryBudget != nil {
+		r.RetryBudget.OnRequest()
+	}
+	err = operation()
+	for i := 0; (i < r.MaxRetries) && isRedisRetryableError(err); i++ {
+		if (r.RetryBudget != nil) && (!r.RetryBudget.TryWithdraw()) {
+			log.Log.Warnf("Retry budget exhausted in Redis %s, fail fast (%s)", r.NameId, err.Error())
+			return err
+		}
+		time.Sleep(redisRetryBackoff << i)
+		err = operation()
+	}
+	return err
+}
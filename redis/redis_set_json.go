@@ -0,0 +1,61 @@
+package redis
+
+import (
+	"encoding/json"
+
+	"github.com/donnyhardyanto/dxlib/log"
+	"github.com/donnyhardyanto/dxlib/utils"
+)
+
+// SAddJSON adds members to the set key as marshalled JSON and returns how many were new. Set membership compares the
+// marshalled bytes: encoding/json writes map keys sorted at every level, so members built as utils.JSON deduplicate
+// whatever their field order, but members added by other clients with another key order or whitespace never match.
+// Members are stored without schema version header or compression.
+func (r *DXRedis) SAddJSON(key string, members ...utils.JSON) (addedCount int64, err error) {
+	if len(members) == 0 {
+		return 0, nil
+	}
+	values := make([]any, len(members))
+	for i, member := range members {
+		memberAsBytes, err := json.Marshal(member)
+		if err != nil {
+			log.Log.Errorf("Cannot marshal set member for Redis %s (%v) %s/%v", r.NameId, err, key, member)
+			return 0, err
+		}
+		values[i] = memberAsBytes
+	}
+	err = r.withRetry(func() (err error) {
+		addedCount, err = r.Connection.SAdd(r.Context, key, values...).Result()
+		return err
+	})
+	if err != nil {
+		log.Log.Errorf("Cannot add set members to Redis %s (%v) %s", r.NameId, err, key)
+		return 0, err
+	}
+	return addedCount, nil
+}
+
+// SMembersJSON returns every member of the set key unmarshalled, in no particular order. A member that is not a JSON
+// object fails the whole call.
+func (r *DXRedis) SMembersJSON(key string) (members []utils.JSON, err error) {
+	var values []string
+	err = r.withRetry(func() (err error) {
+		values, err = r.Connection.SMembers(r.Context, key).Result()
+		return err
+	})
+	if err != nil {
+		log.Log.Errorf("Cannot get set members from Redis %s (%v) %s", r.NameId, err, key)
+		return nil, err
+	}
+	members = make([]utils.JSON, 0, len(values))
+	for _, value := range values {
+		var member utils.JSON
+		err = json.Unmarshal([]byte(value), &member)
+		if err != nil {
+			log.Log.Errorf("Cannot unmarshall set member in Redis %s (%v) %s/%s", r.NameId, err, key, value)
+			return nil, err
+		}
+		members = append(members, member)
+	}
+	return members, nil
+}
@@ -0,0 +1,76 @@
+package redis
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/donnyhardyanto/dxlib/log"
+	"github.com/donnyhardyanto/dxlib/utils"
+)
+
+var ErrRedisKeysNotInSameShard = errors.New(`REDIS_KEYS_NOT_IN_SAME_SHARD`)
+
+type DXRedisSetItem struct {
+	Key        string
+	Value      utils.JSON
+	Expiration time.Duration
+}
+
+// SetManyAtomic writes every item in one MULTI/EXEC transaction, so either all of them are applied or none. A
+// transaction cannot span Ring shards: every key must map to the same shard, use a common hash tag ("{user:1}:a",
+// "{user:1}:b") when more shards are configured, otherwise ErrRedisKeysNotInSameShard is returned before anything is
+// written. The keys are WATCHed, a concurrent write to one of them fails the transaction with redis.TxFailedErr.
+func (r *DXRedis) SetManyAtomic(items []DXRedisSetItem) (err error) {
+	if len(items) == 0 {
+		return nil
+	}
+	keys := make([]string, len(items))
+	values := make([][]byte, len(items))
+	for i, item := range items {
+		err = r.checkKeyKind(item.Key, RedisKeyKindJSON)
+		if err != nil {
+			return err
+		}
+		valueAsBytes, err := json.Marshal(item.Value)
+		if err != nil {
+			log.Log.Errorf("Cannot marshal value for Redis %s k/v (%v) %s", r.NameId, err, item.Key)
+			return err
+		}
+		valueAsBytes = r.encodeValue(valueAsBytes)
+		err = r.checkValueSize(item.Key, len(valueAsBytes))
+		if err != nil {
+			return err
+		}
+		keys[i] = item.Key
+		values[i] = valueAsBytes
+	}
+	defer func() {
+		for _, key := range keys {
+			r.invalidateL1(key)
+		}
+	}()
+	err = r.Connection.Watch(r.Context, func(tx *redis.Tx) error {
+		_, err := tx.TxPipelined(r.Context, func(pipe redis.Pipeliner) error {
+			for i, item := range items {
+				pipe.Set(r.Context, item.Key, values[i], r.jitterTTL(item.Expiration))
+			}
+			return nil
+		})
+		return err
+	}, keys...)
+	if err != nil {
+		if strings.Contains(err.Error(), "same shard") {
+			err = ErrRedisKeysNotInSameShard
+		}
+		log.Log.Errorf("Cannot save atomically to Redis %s k/v (%v) %v", r.NameId, err, keys)
+		return err
+	}
+	for _, key := range keys {
+		r.recordKeyKind(key, RedisKeyKindJSON)
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package redis
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/donnyhardyanto/dxlib/log"
+	"github.com/donnyhardyanto/dxlib/utils"
+)
+
+const (
+	RedisSetModeNX = "NX"
+	RedisSetModeXX = "XX"
+)
+
+// DXRedisSetOptions are the flags of SetWithOptions. Mode is "", RedisSetModeNX or RedisSetModeXX. At most one of
+// Expiration, ExpireAt and KeepTTL may be set, none of them means no expiration. Get returns the previous value.
+type DXRedisSetOptions struct {
+	Mode       string
+	Expiration time.Duration
+	ExpireAt   time.Time
+	KeepTTL    bool
+	Get        bool
+}
+
+// SetWithOptions writes value with the full SET command flags and reports whether it was written, which is false only
+// when the NX or XX condition failed. With opts.Get the previous value is returned, nil when the key did not exist.
+// GET needs Redis 6.2, combined with NX Redis 7.0.
+func (r *DXRedis) SetWithOptions(key string, value utils.JSON, opts DXRedisSetOptions) (previous utils.JSON, ok bool, err error) {
+	expirationCount := 0
+	if opts.Expiration > 0 {
+		expirationCount++
+	}
+	if !opts.ExpireAt.IsZero() {
+		expirationCount++
+	}
+	if opts.KeepTTL {
+		expirationCount++
+	}
+	if expirationCount > 1 {
+		return nil, false, log.Log.ErrorAndCreateErrorf("Conflicting SET expiration options in Redis %s k/v %s", r.NameId, key)
+	}
+	if (opts.Mode != "") && (opts.Mode != RedisSetModeNX) && (opts.Mode != RedisSetModeXX) {
+		return nil, false, log.Log.ErrorAndCreateErrorf("Invalid SET mode in Redis %s k/v: %s", r.NameId, opts.Mode)
+	}
+	err = r.checkKeyKind(key, RedisKeyKindJSON)
+	if err != nil {
+		return nil, false, err
+	}
+	defer r.invalidateL1(key)
+	valueAsBytes, err := json.Marshal(value)
+	if err != nil {
+		log.Log.Errorf("Cannot save to Redis %s k/v (%v) %s/%v", r.NameId, err, key, value)
+		return nil, false, err
+	}
+	valueAsBytes = r.encodeValue(valueAsBytes)
+	err = r.checkValueSize(key, len(valueAsBytes))
+	if err != nil {
+		return nil, false, err
+	}
+
+	args := []any{"set", key, valueAsBytes}
+	switch {
+	case opts.Expiration > 0:
+		args = append(args, "px", r.jitterTTL(opts.Expiration).Milliseconds())
+	case !opts.ExpireAt.IsZero():
+		args = append(args, "pxat", opts.ExpireAt.UnixMilli())
+	case opts.KeepTTL:
+		args = append(args, "keepttl")
+	}
+	if opts.Mode != "" {
+		args = append(args, opts.Mode)
+	}
+	if opts.Get {
+		args = append(args, "get")
+	}
+
+	var reply any
+	err = r.withRetry(func() (err error) {
+		reply, err = r.Connection.Do(r.Context, args...).Result()
+		return err
+	})
+	isNil := errors.Is(err, redis.Nil)
+	if (err != nil) && !isNil {
+		log.Log.Errorf("Cannot save to Redis %s k/v (%v) %s/%v", r.NameId, err, key, value)
+		return nil, false, err
+	}
+	if !opts.Get {
+		if !isNil {
+			r.recordKeyKind(key, RedisKeyKindJSON)
+		}
+		return nil, !isNil, nil
+	}
+
+	switch opts.Mode {
+	case RedisSetModeNX:
+		ok = isNil
+	case RedisSetModeXX:
+		ok = !isNil
+	default:
+		ok = true
+	}
+	if ok {
+		r.recordKeyKind(key, RedisKeyKindJSON)
+	}
+	if isNil {
+		return nil, ok, nil
+	}
+	previousAsString, isString := reply.(string)
+	if !isString {
+		return nil, ok, log.Log.ErrorAndCreateErrorf("Unexpected SET GET reply in Redis %s k/v %s", r.NameId, key)
+	}
+	previousAsBytes, decoded := r.decodeValue([]byte(previousAsString))
+	if !decoded {
+		log.Log.Debugf("Schema version mismatch in Redis %s k/v, previous value treated as missing %s", r.NameId, key)
+		return nil, ok, nil
+	}
+	err = json.Unmarshal(previousAsBytes, &previous)
+	if err != nil {
+		log.Log.Errorf("Cannot unmarshall from bytes in Redis %s k/v (%s) %s/%v", r.NameId, err.Error(), key, previousAsBytes)
+		return nil, ok, err
+	}
+	return previous, ok, nil
+}
+
+// GetSet writes value and returns the previous value, nil when the key did not exist, atomically. Like GETSET the key
+// loses its TTL, use GetSetKeepTTL to keep it.
+func (r *DXRedis) GetSet(key string, value utils.JSON) (old utils.JSON, err error) {
+	old, _, err = r.SetWithOptions(key, value, DXRedisSetOptions{Get: true})
+	return old, err
+}
+
+// GetSetKeepTTL is GetSet keeping the TTL of the key, with SET GET KEEPTTL.
+func (r *DXRedis) GetSetKeepTTL(key string, value utils.JSON) (old utils.JSON, err error) {
+	old, _, err = r.SetWithOptions(key, value, DXRedisSetOptions{Get: true, KeepTTL: true})
+	return old, err
+}
@@ -0,0 +1,112 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/donnyhardyanto/dxlib/log"
+	"github.com/donnyhardyanto/dxlib/utils"
+)
+
+const RedisWarmCacheDefaultBatchSize = 100
+
+// DXRedisWarmLoader passes the rows WarmCache writes to emit one by one, typically while reading a database query, so
+// the rows never have to be held in memory together. An error returned by emit must be returned as is.
+type DXRedisWarmLoader func(ctx context.Context, emit func(row utils.JSON) error) error
+
+// DXRedisWarmSpec describes how WarmCache fills Redis from the loaded rows. KeyTemplate refers to row fields as
+// {field_name}, for example "country:{code}". Every row is stored as its JSON value, readable with Get.
+type DXRedisWarmSpec struct {
+	KeyTemplate string
+	TTL         time.Duration
+	BatchSize   int
+}
+
+var warmCacheKeyPlaceholderRegexp = regexp.MustCompile(`\{[^{}]*\}`)
+
+func renderWarmCacheKey(keyTemplate string, row utils.JSON) (key string, err error) {
+	key = keyTemplate
+	for k, v := range row {
+		key = strings.ReplaceAll(key, `{`+k+`}`, fmt.Sprint(v))
+	}
+	placeholder := warmCacheKeyPlaceholderRegexp.FindString(key)
+	if placeholder != "" {
+		return "", fmt.Errorf("warm cache key template %s refers to %s missing in the row", keyTemplate, placeholder)
+	}
+	return key, nil
+}
+
+// WarmCache writes one key per row emitted by load to r with spec.TTL, pipelined in batches of spec.BatchSize. A row
+// missing a field of spec.KeyTemplate stops it with an error. It returns the number of keys written, also when it stops
+// on an error.
+func WarmCache(r *DXRedis, load DXRedisWarmLoader, spec DXRedisWarmSpec) (warmedCount int64, err error) {
+	if spec.BatchSize <= 0 {
+		spec.BatchSize = RedisWarmCacheDefaultBatchSize
+	}
+	log.Log.Infof("Warming Redis %s... start", r.NameId)
+	keys := []string{}
+	values := [][]byte{}
+	flush := func() error {
+		if len(keys) == 0 {
+			return nil
+		}
+		_, err := r.Connection.Pipelined(r.Context, func(pipe redis.Pipeliner) error {
+			for i, key := range keys {
+				pipe.Set(r.Context, key, values[i], r.jitterTTL(spec.TTL))
+			}
+			return nil
+		})
+		if err != nil {
+			log.Log.Errorf("Cannot warm Redis %s (%s)", r.NameId, err.Error())
+			return err
+		}
+		warmedCount += int64(len(keys))
+		keys = keys[:0]
+		values = values[:0]
+		return nil
+	}
+	err = load(r.Context, func(row utils.JSON) error {
+		value := make(utils.JSON, len(row))
+		for k, v := range row {
+			if b, ok := v.([]byte); ok {
+				v = string(b)
+			}
+			value[k] = v
+		}
+		valueAsBytes, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		valueAsBytes = r.encodeValue(valueAsBytes)
+		key, err := renderWarmCacheKey(spec.KeyTemplate, value)
+		if err != nil {
+			return err
+		}
+		err = r.checkValueSize(key, len(valueAsBytes))
+		if err != nil {
+			return err
+		}
+		keys = append(keys, key)
+		values = append(values, valueAsBytes)
+		if len(keys) >= spec.BatchSize {
+			return flush()
+		}
+		return nil
+	})
+	if err != nil {
+		log.Log.Errorf("Cannot warm Redis %s (%s)", r.NameId, err.Error())
+		return warmedCount, err
+	}
+	err = flush()
+	if err != nil {
+		return warmedCount, err
+	}
+	log.Log.Infof("Warming Redis %s... done %d keys", r.NameId, warmedCount)
+	return warmedCount, nil
+}